@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/assembler"
+)
+
+// assemble implements "assemble <file.asm>", which writes the machine code of
+// an assembly file next to it as a .hack file. It returns the exit status.
+func assemble(args []string) int {
+	flags := flag.NewFlagSet("assemble", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rewriting-JackCompiler assemble <file.asm>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "expected one asm file")
+		flags.Usage()
+		return exitUsage
+	}
+	arg := flags.Arg(0)
+	if filepath.Ext(arg) != ".asm" {
+		fmt.Fprintf(os.Stderr, "%v is not an asm file\n", arg)
+		return exitUsage
+	}
+	if err := assembleFile(arg, strings.TrimSuffix(arg, ".asm")+".hack"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func assembleFile(asmFileName, hackFileName string) error {
+	inputFile, err := os.Open(asmFileName)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	code, err := assembler.Assemble(asmFileName, inputFile)
+	if err != nil {
+		return err
+	}
+
+	outputFile, err := createAtomic(hackFileName)
+	if err != nil {
+		return err
+	}
+	if err := assembler.WriteHack(outputFile, code); err != nil {
+		outputFile.Abort()
+		return err
+	}
+	return outputFile.Commit()
+}
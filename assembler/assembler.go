@@ -0,0 +1,258 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+const (
+	// VariableBase is the address of the first variable allocated by the
+	// assembler.
+	VariableBase = 16
+	ROMSize      = 32768
+)
+
+var predefined = map[string]uint16{
+	"SP":     0,
+	"LCL":    1,
+	"ARG":    2,
+	"THIS":   3,
+	"THAT":   4,
+	"SCREEN": 16384,
+	"KBD":    24576,
+}
+
+// comps maps the computations of C-instructions to their a and c bits.
+var comps = map[string]uint16{
+	"0":   0x2a,
+	"1":   0x3f,
+	"-1":  0x3a,
+	"D":   0x0c,
+	"A":   0x30,
+	"!D":  0x0d,
+	"!A":  0x31,
+	"-D":  0x0f,
+	"-A":  0x33,
+	"D+1": 0x1f,
+	"A+1": 0x37,
+	"D-1": 0x0e,
+	"A-1": 0x32,
+	"D+A": 0x02,
+	"D-A": 0x13,
+	"A-D": 0x07,
+	"D&A": 0x00,
+	"D|A": 0x15,
+	"M":   0x70,
+	"!M":  0x71,
+	"-M":  0x73,
+	"M+1": 0x77,
+	"M-1": 0x72,
+	"D+M": 0x42,
+	"D-M": 0x53,
+	"M-D": 0x47,
+	"D&M": 0x40,
+	"D|M": 0x55,
+}
+
+// Commutative computations may be written either way.
+var commuted = map[string]string{
+	"A+D": "D+A",
+	"A&D": "D&A",
+	"A|D": "D|A",
+	"M+D": "D+M",
+	"M&D": "D&M",
+	"M|D": "D|M",
+	"1+D": "D+1",
+	"1+A": "A+1",
+	"1+M": "M+1",
+}
+
+var destinations = map[rune]uint16{'A': 4, 'D': 2, 'M': 1}
+
+var jumps = map[string]uint16{
+	"":    0,
+	"JGT": 1,
+	"JEQ": 2,
+	"JGE": 3,
+	"JLT": 4,
+	"JNE": 5,
+	"JLE": 6,
+	"JMP": 7,
+}
+
+type instruction struct {
+	text string
+	line int
+}
+
+// Assemble translates the Hack assembly read from r into machine code. Labels
+// are resolved in a first pass; other symbols are variables allocated from
+// VariableBase on. Errors are returned as a diagnostic.List.
+func Assemble(filename string, r io.Reader) ([]uint16, error) {
+	var diags diagnostic.List
+	var instructions []instruction
+	symbols := map[string]uint16{}
+	for name, address := range predefined {
+		symbols[name] = address
+	}
+	for i := 0; i < 16; i++ {
+		symbols[fmt.Sprintf("R%v", i)] = uint16(i)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.Join(strings.Fields(text), "")
+		if text == "" {
+			continue
+		}
+		if !strings.HasPrefix(text, "(") {
+			instructions = append(instructions, instruction{text, line})
+			continue
+		}
+
+		label := strings.TrimSuffix(text[1:], ")")
+		_, defined := symbols[label]
+		switch {
+		case !strings.HasSuffix(text, ")") || !isSymbol(label):
+			diags.Add(diagnostic.Errorf(filename, line, 1, diagnostic.CodeSyntax, "invalid label %v", text))
+		case defined:
+			diags.Add(diagnostic.Errorf(filename, line, 1, diagnostic.CodeDuplicate, "%v is already defined", label))
+		default:
+			symbols[label] = uint16(len(instructions))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(instructions) > ROMSize {
+		diags.Add(diagnostic.Errorf(filename, 1, 1, diagnostic.CodeInternal,
+			"program has %v instructions, more than the %v of the ROM", len(instructions), ROMSize))
+	}
+
+	code := make([]uint16, 0, len(instructions))
+	nextVariable := uint16(VariableBase)
+	for _, in := range instructions {
+		word, err := encode(in.text, symbols, &nextVariable)
+		if err != "" {
+			diags.Add(diagnostic.Errorf(filename, in.line, 1, diagnostic.CodeSyntax, "%v", err))
+			continue
+		}
+		code = append(code, word)
+	}
+	if err := diags.Err(); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// encode returns the machine code of one instruction, or a message describing
+// what is wrong with it.
+func encode(text string, symbols map[string]uint16, nextVariable *uint16) (uint16, string) {
+	if strings.HasPrefix(text, "@") {
+		value := text[1:]
+		if n, err := strconv.Atoi(value); err == nil {
+			if n < 0 || n > 32767 {
+				return 0, fmt.Sprintf("constant %v is out of range", value)
+			}
+			return uint16(n), ""
+		}
+		if !isSymbol(value) {
+			return 0, fmt.Sprintf("invalid symbol %v", value)
+		}
+		address, ok := symbols[value]
+		if !ok {
+			address = *nextVariable
+			symbols[value] = address
+			*nextVariable++
+		}
+		return address, ""
+	}
+
+	dest, comp, jump := "", text, ""
+	if i := strings.Index(comp, "="); i >= 0 {
+		dest, comp = comp[:i], comp[i+1:]
+	}
+	if i := strings.Index(comp, ";"); i >= 0 {
+		comp, jump = comp[:i], comp[i+1:]
+	}
+
+	var destBits uint16
+	for _, register := range dest {
+		bit := destinations[register]
+		if bit == 0 || destBits&bit != 0 {
+			return 0, fmt.Sprintf("invalid destination %v", dest)
+		}
+		destBits |= bit
+	}
+	if c, ok := commuted[comp]; ok {
+		comp = c
+	}
+	compBits, ok := comps[comp]
+	if !ok {
+		return 0, fmt.Sprintf("invalid computation %v", comp)
+	}
+	jumpBits, ok := jumps[jump]
+	if !ok {
+		return 0, fmt.Sprintf("invalid jump %v", jump)
+	}
+	return 0xe000 | compBits<<6 | destBits<<3 | jumpBits, ""
+}
+
+// isSymbol reports whether s is a sequence of letters, digits, '_', '.', '$'
+// and ':' not beginning with a digit.
+func isSymbol(s string) bool {
+	if s == "" || '0' <= s[0] && s[0] <= '9' {
+		return false
+	}
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune("_.$:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteHack writes code as text, one instruction per line in binary, as the
+// CPU emulator of the course loads it.
+func WriteHack(w io.Writer, code []uint16) error {
+	bw := bufio.NewWriter(w)
+	for _, word := range code {
+		fmt.Fprintf(bw, "%016b\n", word)
+	}
+	return bw.Flush()
+}
+
+// ReadHack reads code written by WriteHack.
+func ReadHack(filename string, r io.Reader) ([]uint16, error) {
+	var diags diagnostic.List
+	var code []uint16
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		word, err := strconv.ParseUint(text, 2, 16)
+		if err != nil || len(text) != 16 {
+			diags.Add(diagnostic.Errorf(filename, line, 1, diagnostic.CodeSyntax, "invalid instruction %v", text))
+			continue
+		}
+		code = append(code, uint16(word))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := diags.Err(); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
@@ -0,0 +1,70 @@
+package assembler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	code, err := Assemble("Max.asm", strings.NewReader(`// Computes R2 = max(R0, R1)
+   @R0
+   D=M              // D = first number
+   @R1
+   D=D-M            // D = first number - second number
+   @OUTPUT_FIRST
+   D;JGT            // if D>0 (first is greater) goto output_first
+   @R1
+   D=M              // D = second number
+   @OUTPUT_D
+   0;JMP            // goto output_d
+(OUTPUT_FIRST)
+   @R0
+   D=M              // D = first number
+(OUTPUT_D)
+   @R2
+   M=D              // M[2] = D (greatest number)
+   @counter
+   AM=M+D
+(INFINITE_LOOP)
+   @INFINITE_LOOP
+   0;JMP
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	WriteHack(&out, code)
+	expect := `0000000000000000
+1111110000010000
+0000000000000001
+1111010011010000
+0000000000001010
+1110001100000001
+0000000000000001
+1111110000010000
+0000000000001100
+1110101010000111
+0000000000000000
+1111110000010000
+0000000000000010
+1110001100001000
+0000000000010000
+1111000010101000
+0000000000010000
+1110101010000111
+`
+	if actual := out.String(); actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	_, err := Assemble("Bad.asm", strings.NewReader("(LOOP)\n(LOOP)\n@40000\nD=X\nAA=D\nD;JMPS\n@1x\n"))
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	if lines := strings.Count(err.Error(), "\n") + 1; lines != 6 {
+		t.Errorf("expected 6 errors, got:\n%v", err)
+	}
+}
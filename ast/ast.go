@@ -0,0 +1,197 @@
+package ast
+
+// Pos is a location in a Jack source file. Line and Column start at 1.
+type Pos struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Node is implemented by every node of the tree.
+type Node interface {
+	Position() Pos
+}
+
+// Statement is implemented by let, if, while, do and return statements.
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Expression is implemented by binary expressions and by every kind of term.
+type Expression interface {
+	Node
+	expressionNode()
+}
+
+// Ident is a name together with the place it appears.
+type Ident struct {
+	Pos  Pos
+	Name string
+}
+
+type Class struct {
+	Pos          Pos
+	Name         *Ident
+	ClassVarDecs []*ClassVarDec
+	Subroutines  []*Subroutine
+}
+
+type ClassVarDec struct {
+	Pos   Pos
+	Kind  string // "static" | "field"
+	Type  string
+	Names []*Ident
+}
+
+type Subroutine struct {
+	Pos        Pos
+	Kind       string // "constructor" | "function" | "method"
+	ReturnType string
+	Name       *Ident
+	Params     []*Param
+	VarDecs    []*VarDec
+	Statements []Statement
+}
+
+type Param struct {
+	Pos  Pos
+	Type string
+	Name *Ident
+}
+
+type VarDec struct {
+	Pos   Pos
+	Type  string
+	Names []*Ident
+}
+
+// Statements
+
+type LetStatement struct {
+	Pos   Pos
+	Name  *Ident
+	Index Expression // nil unless assigning to an array element
+	Value Expression
+}
+
+type IfStatement struct {
+	Pos  Pos
+	Cond Expression
+	Then []Statement
+	Else []Statement // nil when there is no else branch
+}
+
+type WhileStatement struct {
+	Pos  Pos
+	Cond Expression
+	Body []Statement
+}
+
+type DoStatement struct {
+	Pos  Pos
+	Call *CallExpr
+}
+
+type ReturnStatement struct {
+	Pos   Pos
+	Value Expression // nil for a bare return
+}
+
+// Expressions
+
+type IntegerConstant struct {
+	Pos   Pos
+	Value int
+}
+
+type StringConstant struct {
+	Pos   Pos
+	Value string
+}
+
+// KeywordConstant is one of true, false, null and this.
+type KeywordConstant struct {
+	Pos     Pos
+	Keyword string
+}
+
+type VarExpr struct {
+	Pos  Pos
+	Name string
+}
+
+// IndexExpr is an array access such as a[i].
+type IndexExpr struct {
+	Pos   Pos
+	Name  string
+	Index Expression
+}
+
+// CallExpr is a subroutine call. Receiver is the class or variable before the
+// dot, or "" for a call such as f(x) on the current object.
+type CallExpr struct {
+	Pos      Pos
+	Receiver string
+	Name     string
+	Args     []Expression
+}
+
+type UnaryExpr struct {
+	Pos     Pos
+	Op      string // "-" | "~"
+	Operand Expression
+}
+
+// BinaryExpr is "Left Op Right". Jack has no operator precedence, so a chain
+// such as a + b * c is parsed left to right as ((a + b) * c).
+type BinaryExpr struct {
+	Pos   Pos
+	Op    string
+	Left  Expression
+	Right Expression
+}
+
+// ParenExpr is a parenthesized expression. It is kept in the tree so that the
+// source structure can be reproduced exactly.
+type ParenExpr struct {
+	Pos Pos
+	X   Expression
+}
+
+func (n *Ident) Position() Pos           { return n.Pos }
+func (n *Class) Position() Pos           { return n.Pos }
+func (n *ClassVarDec) Position() Pos     { return n.Pos }
+func (n *Subroutine) Position() Pos      { return n.Pos }
+func (n *Param) Position() Pos           { return n.Pos }
+func (n *VarDec) Position() Pos          { return n.Pos }
+func (n *LetStatement) Position() Pos    { return n.Pos }
+func (n *IfStatement) Position() Pos     { return n.Pos }
+func (n *WhileStatement) Position() Pos  { return n.Pos }
+func (n *DoStatement) Position() Pos     { return n.Pos }
+func (n *ReturnStatement) Position() Pos { return n.Pos }
+func (n *IntegerConstant) Position() Pos { return n.Pos }
+func (n *StringConstant) Position() Pos  { return n.Pos }
+func (n *KeywordConstant) Position() Pos { return n.Pos }
+func (n *VarExpr) Position() Pos         { return n.Pos }
+func (n *IndexExpr) Position() Pos       { return n.Pos }
+func (n *CallExpr) Position() Pos        { return n.Pos }
+func (n *UnaryExpr) Position() Pos       { return n.Pos }
+func (n *BinaryExpr) Position() Pos      { return n.Pos }
+func (n *ParenExpr) Position() Pos       { return n.Pos }
+
+func (*LetStatement) statementNode()    {}
+func (*IfStatement) statementNode()     {}
+func (*WhileStatement) statementNode()  {}
+func (*DoStatement) statementNode()     {}
+func (*ReturnStatement) statementNode() {}
+
+func (*IntegerConstant) expressionNode() {}
+func (*StringConstant) expressionNode()  {}
+func (*KeywordConstant) expressionNode() {}
+func (*VarExpr) expressionNode()         {}
+func (*IndexExpr) expressionNode()       {}
+func (*CallExpr) expressionNode()        {}
+func (*UnaryExpr) expressionNode()       {}
+func (*BinaryExpr) expressionNode()      {}
+func (*ParenExpr) expressionNode()       {}
@@ -0,0 +1,73 @@
+package ast
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node. If f returns false, the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Class:
+		Inspect(n.Name, f)
+		for _, dec := range n.ClassVarDecs {
+			Inspect(dec, f)
+		}
+		for _, sub := range n.Subroutines {
+			Inspect(sub, f)
+		}
+	case *ClassVarDec:
+		for _, name := range n.Names {
+			Inspect(name, f)
+		}
+	case *Subroutine:
+		Inspect(n.Name, f)
+		for _, param := range n.Params {
+			Inspect(param, f)
+		}
+		for _, dec := range n.VarDecs {
+			Inspect(dec, f)
+		}
+		inspectStatements(n.Statements, f)
+	case *Param:
+		Inspect(n.Name, f)
+	case *VarDec:
+		for _, name := range n.Names {
+			Inspect(name, f)
+		}
+	case *LetStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Index, f)
+		Inspect(n.Value, f)
+	case *IfStatement:
+		Inspect(n.Cond, f)
+		inspectStatements(n.Then, f)
+		inspectStatements(n.Else, f)
+	case *WhileStatement:
+		Inspect(n.Cond, f)
+		inspectStatements(n.Body, f)
+	case *DoStatement:
+		Inspect(n.Call, f)
+	case *ReturnStatement:
+		Inspect(n.Value, f)
+	case *IndexExpr:
+		Inspect(n.Index, f)
+	case *CallExpr:
+		for _, arg := range n.Args {
+			Inspect(arg, f)
+		}
+	case *UnaryExpr:
+		Inspect(n.Operand, f)
+	case *BinaryExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *ParenExpr:
+		Inspect(n.X, f)
+	}
+}
+
+func inspectStatements(statements []Statement, f func(Node) bool) {
+	for _, statement := range statements {
+		Inspect(statement, f)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/cache"
+	"github.com/jinpei0908/rewriting-JackCompiler/compiler"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// artifacts are the files build can write for each class, by name, with
+// the ending replacing ".jack" in their file names.
+var artifacts = map[string]string{
+	"vm":      ".vm",
+	"xml":     ".xml",
+	"tokens":  ".tokens",
+	"txml":    "T.xml",
+	"symbols": ".symbols",
+}
+
+// artifactOrder is the order in which the artifacts are written.
+var artifactOrder = []string{"vm", "xml", "tokens", "txml", "symbols"}
+
+// stages are the stages of the build commands, with the artifacts they emit
+// by default.
+var stages = map[string]struct {
+	stage compiler.Stage
+	emit  string
+}{
+	"tokenize": {compiler.Tokenize, "txml"},
+	"parse":    {compiler.Parse, "xml"},
+	"check":    {compiler.Check, ""},
+	"compile":  {compiler.Generate, "vm"},
+	"watch":    {compiler.Generate, "vm"},
+}
+
+// optLevel is the value of the -O flag, which may be given alone for -O=1.
+type optLevel int
+
+func (l *optLevel) String() string {
+	return strconv.Itoa(int(*l))
+}
+
+func (l *optLevel) Set(s string) error {
+	switch s {
+	case "true", "1":
+		*l = 1
+	case "false", "0":
+		*l = 0
+	default:
+		return fmt.Errorf("unknown optimization level %v, expected 0 or 1", s)
+	}
+	return nil
+}
+
+func (l *optLevel) IsBoolFlag() bool {
+	return true
+}
+
+// buildFlags are the flags of the compile, tokenize, parse and check
+// commands.
+type buildFlags struct {
+	outDir     string
+	suffix     string
+	emit       string
+	opt        optLevel
+	typeCheck  bool
+	typeErrors bool
+	werror     bool
+	xmlInfo    bool
+	quiet      bool
+	verbose    bool
+	hack       bool
+	filter     sourceFilter
+	jobs       int
+	cacheDir   string
+	cache      *cache.Cache
+}
+
+func newBuildFlags(command string) (*flag.FlagSet, *buildFlags) {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	b := &buildFlags{}
+	flags.StringVar(&b.outDir, "o", "", "write the outputs into `dir`, mirroring the layout of the sources, instead of next to them; - for the standard output")
+	flags.StringVar(&b.suffix, "suffix", "", "`text` added to the class name in the names of the outputs, such as _ for Main_.vm")
+	flags.StringVar(&b.emit, "emit", stages[command].emit, "comma-separated `artifacts` to write: vm, xml, tokens, txml (the xxxT.xml tokens of the course), symbols")
+	flags.Var(&b.opt, "O", "optimization `level`: 0 for none, 1 to fold constants and simplify the VM code")
+	flags.BoolVar(&b.typeCheck, "typecheck", false, "check the types of expressions, assignments, calls and returns")
+	flags.BoolVar(&b.typeErrors, "typeerrors", false, "report type mismatches as errors instead of warnings")
+	flags.BoolVar(&b.werror, "Werror", false, "report every warning as an error")
+	flags.BoolVar(&b.xmlInfo, "xmlinfo", false, "follow each identifier of the XML syntax trees with an <identifierInfo> element describing its symbol")
+	flags.BoolVar(&b.quiet, "q", false, "print errors only")
+	flags.BoolVar(&b.verbose, "v", false, "also print the files written")
+	flags.IntVar(&b.jobs, "j", runtime.NumCPU(), "build `n` classes at a time")
+	flags.StringVar(&b.cacheDir, "cache", "", "keep the outputs of the classes in `dir` and reuse them while the sources, options and signatures of the program are unchanged")
+	flags.BoolVar(&b.filter.recursive, "r", false, "also build the programs in the subdirectories of the directories")
+	flags.Var(&b.filter.include, "include", "build only the files matching `pattern`, by name or path below the directory; may be repeated")
+	flags.Var(&b.filter.exclude, "exclude", "skip the files and directories matching `pattern`; may be repeated")
+	if command == "compile" {
+		flags.BoolVar(&b.hack, "hack", false, "also translate and assemble each program into <dir>.hack, next to its VM files")
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: rewriting-JackCompiler %v [flags] <file.jack|dir>...\n", command)
+		flags.PrintDefaults()
+	}
+	return flags, b
+}
+
+// build implements the compile, tokenize, parse and check commands, which
+// take the Jack classes of files and directories up to the stage of the
+// command, and write the artifacts asked for. Each directory is a program,
+// whose classes are checked against each other. It returns the exit status.
+func build(command string, args []string) int {
+	flags, b := newBuildFlags(command)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	stage, emit, err := b.check(flags, command)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	programs, err := collectPrograms(flags.Args(), b.filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return inputStatus(err)
+	}
+
+	var jobs []*job
+	failed := map[*program]bool{}
+	for _, p := range programs {
+		c, err := b.newCompiler(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed[p] = true
+			continue
+		}
+		for _, file := range p.files {
+			jobs = append(jobs, &job{program: p, compiler: c, file: file})
+		}
+	}
+	b.runJobs(jobs, stage, emit)
+
+	status := exitOK
+	if len(failed) > 0 {
+		status = exitFailure
+	}
+	vmFileNames := map[*program][]string{}
+	for _, j := range jobs {
+		if j.err != nil {
+			failed[j.program] = true
+			status = exitFailure
+		}
+		vmFileNames[j.program] = append(vmFileNames[j.program], j.outputs["vm"])
+	}
+	if b.hack {
+		for _, p := range programs {
+			if failed[p] {
+				continue
+			}
+			if err := buildHack(p.dir, b.outputDir(p), p.files, vmFileNames[p]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = exitFailure
+			}
+		}
+	}
+	return status
+}
+
+// check validates the parsed flags of command and returns the stage to reach
+// and the artifacts to emit. It also opens the cache.
+func (b *buildFlags) check(flags *flag.FlagSet, command string) (compiler.Stage, map[string]bool, error) {
+	stage := stages[command].stage
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return stage, nil, fmt.Errorf("expected files or directories")
+	}
+	if b.jobs < 1 {
+		return stage, nil, fmt.Errorf("-j must be at least 1")
+	}
+	if b.quiet && b.verbose {
+		return stage, nil, fmt.Errorf("-q and -v cannot be used together")
+	}
+	emit, err := parseEmit(b.emit, stage)
+	if err != nil {
+		return stage, nil, err
+	}
+	if b.hack && (!emit["vm"] || b.outDir == "-") {
+		return stage, nil, fmt.Errorf("-hack needs the vm artifact written to files")
+	}
+	if b.cacheDir != "" {
+		if b.cache, err = cache.Open(b.cacheDir); err != nil {
+			return stage, nil, err
+		}
+	}
+	if b.outDir == "-" {
+		// Only the outputs go to the standard output.
+		b.quiet = true
+		b.verbose = false
+	}
+	return stage, emit, nil
+}
+
+// options returns the compiler options selected by the flags.
+func (b *buildFlags) options() compiler.Options {
+	return compiler.Options{
+		TypeCheck:        b.typeCheck,
+		TypeErrors:       b.typeErrors,
+		WarningsAsErrors: b.werror,
+		Optimize:         b.opt > 0,
+		IdentifierInfo:   b.xmlInfo}
+}
+
+// newCompiler returns a compiler for the classes of a program.
+func (b *buildFlags) newCompiler(p *program) (*compiler.Compiler, error) {
+	c := compiler.New(b.options())
+	for _, file := range p.classes {
+		if err := c.AddFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// parseEmit returns the set of artifacts listed in emit, checking that stage
+// produces them.
+func parseEmit(emit string, stage compiler.Stage) (map[string]bool, error) {
+	set := map[string]bool{}
+	for _, name := range strings.Split(emit, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case artifacts[name] == "":
+			return nil, fmt.Errorf("unknown artifact %v", name)
+		case name == "vm" && stage < compiler.Generate,
+			name != "tokens" && name != "txml" && stage < compiler.Parse:
+			return nil, fmt.Errorf("%v is not produced at this stage", name)
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// outputName returns the name of the file of an artifact of the class in
+// file, root being the directory given on the command line.
+func (b *buildFlags) outputName(root, file, artifact string) string {
+	name := strings.TrimSuffix(file, ".jack") + b.suffix + artifacts[artifact]
+	if b.outDir == "" {
+		return name
+	}
+	rel, err := filepath.Rel(root, name)
+	if err != nil {
+		rel = filepath.Base(name)
+	}
+	return filepath.Join(b.outDir, rel)
+}
+
+// outputDir returns the directory of the outputs of the program as a whole,
+// which mirrors its directory under -o as outputName does for its classes.
+func (b *buildFlags) outputDir(p *program) string {
+	if b.outDir == "" {
+		return p.dir
+	}
+	rel, err := filepath.Rel(p.root, p.dir)
+	if err != nil {
+		rel = filepath.Base(p.dir)
+	}
+	return filepath.Join(b.outDir, rel)
+}
+
+// buildFile builds the class of a job and returns the names of the files
+// of its outputs, by artifact. The files are only written if the class has
+// no errors, and only those whose content changes are rewritten.
+func (b *buildFlags) buildFile(j *job, stage compiler.Stage, emit map[string]bool) (map[string]string, error) {
+	if !b.quiet {
+		fmt.Fprintln(&j.stdout, j.file)
+	}
+	src, err := os.ReadFile(j.file)
+	if err != nil {
+		return nil, err
+	}
+
+	var key string
+	var entry *cache.Entry
+	var cached bool
+	if b.cache != nil {
+		key = b.cacheKey(j, stage, emit, src)
+		entry, cached = b.cache.Get(key)
+	}
+	if !cached {
+		entry, err = b.compile(j, stage, emit, src)
+		if entry == nil {
+			return nil, err
+		}
+		if err == nil && b.cache != nil {
+			if putErr := b.cache.Put(key, entry); putErr != nil {
+				fmt.Fprintln(&j.stderr, "cache:", putErr)
+			}
+		}
+	}
+	for _, d := range entry.Diagnostics {
+		if d.Severity == diagnostic.Error || !b.quiet {
+			fmt.Fprintln(&j.stderr, d)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, artifact := range artifactOrder {
+		if !emit[artifact] {
+			continue
+		}
+		if b.outDir == "-" {
+			j.stdout.Write(entry.Artifacts[artifact])
+			continue
+		}
+		name := b.outputName(j.program.root, j.file, artifact)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return names, err
+		}
+		written, err := writeIfChanged(name, entry.Artifacts[artifact])
+		if err != nil {
+			return names, err
+		}
+		names[artifact] = name
+		if b.verbose && written {
+			fmt.Fprintln(&j.stdout, "wrote", name)
+		} else if b.verbose {
+			fmt.Fprintln(&j.stdout, "unchanged", name)
+		}
+	}
+	return names, nil
+}
+
+// compile builds the class src of a job into memory. It returns a nil entry
+// only for errors that are not diagnostics.
+func (b *buildFlags) compile(j *job, stage compiler.Stage, emit map[string]bool, src []byte) (*cache.Entry, error) {
+	buffers := map[string]*bytes.Buffer{}
+	writer := func(artifact string) io.Writer {
+		if !emit[artifact] {
+			return nil
+		}
+		buffers[artifact] = &bytes.Buffer{}
+		return buffers[artifact]
+	}
+	diags, err := j.compiler.CompileStage(j.file, bytes.NewReader(src), stage, compiler.Outputs{
+		VM:        writer("vm"),
+		XML:       writer("xml"),
+		Tokens:    writer("tokens"),
+		TokensXML: writer("txml"),
+		Symbols:   writer("symbols")})
+	if _, ok := err.(diagnostic.List); err != nil && !ok {
+		return nil, err
+	}
+	entry := &cache.Entry{Diagnostics: diags, Artifacts: map[string][]byte{}}
+	for artifact, buf := range buffers {
+		entry.Artifacts[artifact] = buf.Bytes()
+	}
+	return entry, err
+}
+
+// cacheKey returns the key of the outputs of a job in the cache. It covers
+// everything the outputs depend on: the compiler and its options, the
+// artifacts asked for, the file, whose name appears in the diagnostics, its
+// source, and the signatures of the other classes of the program.
+func (b *buildFlags) cacheKey(j *job, stage compiler.Stage, emit map[string]bool, src []byte) string {
+	var emitted []string
+	for _, artifact := range artifactOrder {
+		if emit[artifact] {
+			emitted = append(emitted, artifact)
+		}
+	}
+	return cache.Key(
+		[]byte(compiler.Version),
+		[]byte(fmt.Sprintf("%+v stage=%v emit=%v", j.compiler.Options(), stage, emitted)),
+		[]byte(j.file),
+		src,
+		[]byte(j.compiler.SignatureDigest()))
+}
+
+// buildHack translates the classes compiled from jackFileNames into
+// vmFileNames, along with the VM files in dir of the classes not compiled
+// from Jack, such as the OS, and assembles the result into
+// <outDir>/<dir>.hack, dir standing for the base name of the directory.
+func buildHack(dir, outDir string, jackFileNames, vmFileNames []string) error {
+	compiled := map[string]bool{}
+	for _, file := range jackFileNames {
+		compiled[strings.TrimSuffix(filepath.Base(file), ".jack")] = true
+	}
+	others, _ := filepath.Glob(filepath.Join(dir, "*.vm"))
+	for _, file := range others {
+		className := strings.TrimSuffix(filepath.Base(file), ".vm")
+		// Main_.vm is the output of a former compilation of Main.jack.
+		if !compiled[className] && !compiled[strings.TrimSuffix(className, "_")] {
+			vmFileNames = append(vmFileNames, file)
+		}
+	}
+
+	// The base name of "." is the name of the working directory.
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(outDir, filepath.Base(abs))
+	if err := translateFiles(vmFileNames, name+".asm", true); err != nil {
+		return err
+	}
+	return assembleFile(name+".asm", name+".hack")
+}
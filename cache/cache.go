@@ -0,0 +1,94 @@
+// Package cache stores the results of compilations on disk, keyed by a digest
+// of everything they depend on, so that unchanged classes are not compiled
+// again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// Entry is the result of the compilation of a class.
+type Entry struct {
+	// Diagnostics are the warnings found by the compilation, replayed when
+	// the entry is used. Failed compilations are not stored.
+	Diagnostics diagnostic.List
+	// Artifacts are the contents of the outputs, by artifact.
+	Artifacts map[string][]byte
+}
+
+// Cache is a directory of entries. Entries are written atomically, so a
+// cache may be shared by concurrent builds.
+type Cache struct {
+	dir string
+}
+
+// Open returns the cache in dir, creating the directory if needed.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Key returns the digest of parts, which are all the inputs of a
+// compilation.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// Prefixing the lengths keeps ("ab", "c") and ("a", "bc") apart.
+		binary.Write(h, binary.BigEndian, uint64(len(part)))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get returns the entry of key. A missing or unreadable entry is a miss.
+func (c *Cache) Get(key string) (*Entry, bool) {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+// Put stores e as the entry of key.
+func (c *Cache) Put(key string, e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".entry.*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
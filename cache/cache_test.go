@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+func TestPutAndGet(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key([]byte("1.0"), []byte("class Main {}"))
+	if _, ok := c.Get(key); ok {
+		t.Fatal("hit in an empty cache")
+	}
+
+	entry := &Entry{
+		Diagnostics: diagnostic.List{diagnostic.Warningf("Main.jack", 2, 5, diagnostic.CodeType, "int expected")},
+		Artifacts:   map[string][]byte{"vm": []byte("push constant 0\nreturn\n")},
+	}
+	if err := c.Put(key, entry); err != nil {
+		t.Fatal(err)
+	}
+	actual, ok := c.Get(key)
+	if !ok {
+		t.Fatal("miss after Put")
+	}
+	if string(actual.Artifacts["vm"]) != "push constant 0\nreturn\n" ||
+		len(actual.Diagnostics) != 1 || actual.Diagnostics[0].Error() != entry.Diagnostics[0].Error() {
+		t.Errorf("unexpected entry: %+v", actual)
+	}
+
+	os.WriteFile(c.path(key), []byte("{"), 0644)
+	if _, ok := c.Get(key); ok {
+		t.Error("hit on a corrupted entry")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key([]byte("ab"), []byte("c")) == Key([]byte("a"), []byte("bc")) {
+		t.Error("keys of different parts collide")
+	}
+	if Key([]byte("a")) != Key([]byte("a")) {
+		t.Error("keys are not deterministic")
+	}
+}
@@ -0,0 +1,173 @@
+package checker
+
+import (
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/symboltable"
+)
+
+// Checker performs the semantic analysis of one class: every variable must be
+// declared exactly once in its scope before it is used, and functions must
+// not use this, neither explicitly nor through fields or method calls. Calls
+// must match the kind and parameter count of the subroutine they invoke.
+type Checker struct {
+	st        *symboltable.SymbolTable
+	opts      Options
+	filename  string
+	className string
+	index     *Index
+	current   *ast.Subroutine
+	subKind   string
+	diags     diagnostic.List
+}
+
+// Options select the optional analyses of the checker.
+type Options struct {
+	// TypeCheck enables the static type checker.
+	TypeCheck bool
+	// TypeErrors reports type mismatches as errors instead of warnings.
+	TypeErrors bool
+	// WarningsAsErrors reports every warning as an error.
+	WarningsAsErrors bool
+	// Index describes every class of the program. Without it, only calls
+	// into the class being checked and into the OS are validated.
+	Index *Index
+}
+
+func NewChecker(filename string, opts Options) *Checker {
+	return &Checker{
+		st:       symboltable.NewSymbolTable(),
+		opts:     opts,
+		filename: filename}
+}
+
+// CheckClass checks class and returns the diagnostics found as a
+// diagnostic.List if any of them is an error.
+func (c *Checker) CheckClass(class *ast.Class) error {
+	c.className = class.Name.Name
+	c.index = c.opts.Index
+	if c.index == nil {
+		c.index = NewIndex()
+		c.index.AddClass(class)
+	}
+	for _, dec := range class.ClassVarDecs {
+		for _, name := range dec.Names {
+			c.define(name, dec.Type, dec.Kind)
+		}
+	}
+	for _, sub := range class.Subroutines {
+		c.checkSubroutine(sub)
+	}
+	return c.diags.Err()
+}
+
+// Diagnostics returns every error and warning found by CheckClass.
+func (c *Checker) Diagnostics() diagnostic.List {
+	return c.diags
+}
+
+func (c *Checker) checkSubroutine(sub *ast.Subroutine) {
+	c.current = sub
+	c.subKind = sub.Kind
+	c.st.StartSubroutine(sub.Kind)
+	for _, param := range sub.Params {
+		c.define(param.Name, param.Type, "arg")
+	}
+	for _, dec := range sub.VarDecs {
+		for _, name := range dec.Names {
+			c.define(name, dec.Type, "var")
+		}
+	}
+	c.checkStatements(sub.Statements)
+}
+
+func (c *Checker) checkStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.LetStatement:
+			if _, err := c.st.KindOf(s.Name.Name); err != nil {
+				c.errorf(s.Name.Pos, diagnostic.CodeUndefined, "assignment to undeclared variable %v", s.Name.Name)
+			} else {
+				c.checkField(s.Name.Name, s.Name.Pos)
+			}
+			c.checkExpression(s.Index)
+			c.checkExpression(s.Value)
+			if c.opts.TypeCheck {
+				c.checkLetTypes(s)
+			}
+		case *ast.IfStatement:
+			c.checkExpression(s.Cond)
+			if c.opts.TypeCheck {
+				c.checkCondition(s.Cond)
+			}
+			c.checkStatements(s.Then)
+			c.checkStatements(s.Else)
+		case *ast.WhileStatement:
+			c.checkExpression(s.Cond)
+			if c.opts.TypeCheck {
+				c.checkCondition(s.Cond)
+			}
+			c.checkStatements(s.Body)
+		case *ast.DoStatement:
+			c.checkExpression(s.Call)
+			if c.opts.TypeCheck {
+				c.typeOf(s.Call)
+			}
+		case *ast.ReturnStatement:
+			c.checkExpression(s.Value)
+			if c.opts.TypeCheck {
+				c.checkReturnType(s)
+			}
+		}
+	}
+}
+
+func (c *Checker) checkExpression(expr ast.Expression) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch e := node.(type) {
+		case *ast.KeywordConstant:
+			if e.Keyword == "this" && c.subKind == "function" {
+				c.errorf(e.Pos, diagnostic.CodeNoThis, "this cannot be used in a function")
+			}
+		case *ast.VarExpr:
+			c.checkVariable(e.Name, e.Pos)
+		case *ast.IndexExpr:
+			c.checkVariable(e.Name, e.Pos)
+		case *ast.CallExpr:
+			if e.Receiver == "" && c.subKind == "function" {
+				c.errorf(e.Pos, diagnostic.CodeNoThis, "method %v cannot be called without an object in a function", e.Name)
+			}
+			// A receiver that is not a variable is a class name.
+			if _, err := c.st.KindOf(e.Receiver); err == nil {
+				c.checkField(e.Receiver, e.Pos)
+			}
+			c.checkCall(e)
+		}
+		return true
+	})
+}
+
+func (c *Checker) checkVariable(name string, pos ast.Pos) {
+	if _, err := c.st.KindOf(name); err != nil {
+		c.errorf(pos, diagnostic.CodeUndefined, "undefined variable %v", name)
+		return
+	}
+	c.checkField(name, pos)
+}
+
+// checkField reports a field used where there is no this.
+func (c *Checker) checkField(name string, pos ast.Pos) {
+	if kind, _ := c.st.KindOf(name); kind == "Field" && c.subKind == "function" {
+		c.errorf(pos, diagnostic.CodeNoThis, "field %v cannot be used in a function", name)
+	}
+}
+
+func (c *Checker) define(name *ast.Ident, type_, kind string) {
+	if err := c.st.Define(name.Name, type_, kind); err != nil {
+		c.errorf(name.Pos, diagnostic.CodeDuplicate, "%v", err)
+	}
+}
+
+func (c *Checker) errorf(pos ast.Pos, code, format string, args ...interface{}) {
+	c.diags.Add(diagnostic.Errorf(c.filename, pos.Line, pos.Column, code, format, args...))
+}
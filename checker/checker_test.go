@@ -0,0 +1,169 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/internal/jacktest"
+)
+
+func checkString(t *testing.T, src string) error {
+	return checkStringWithOptions(t, src, Options{})
+}
+
+func checkStringWithOptions(t *testing.T, src string, opts Options) error {
+	return NewChecker("Main.jack", opts).CheckClass(jacktest.Parse(t, src))
+}
+
+func TestCheckClass(t *testing.T) {
+	err := checkString(t, `class Main {
+  field int x, x;
+  static Main instance;
+  function void main(int a) {
+    var int a, b;
+    let c = b + d[0];
+    let x = this;
+    do draw();
+    do instance.draw();
+    return;
+  }
+  method void draw() {
+    let x = x + 1;
+    do draw();
+    return;
+  }
+}
+`)
+	diags, ok := err.(diagnostic.List)
+	if !ok {
+		t.Fatalf("expected diagnostics, got %v", err)
+	}
+
+	type expect struct {
+		line, column int
+		code         string
+	}
+	expects := []expect{
+		{2, 16, diagnostic.CodeDuplicate},
+		{5, 13, diagnostic.CodeDuplicate},
+		{6, 9, diagnostic.CodeUndefined},
+		{6, 17, diagnostic.CodeUndefined},
+		{7, 9, diagnostic.CodeNoThis},
+		{7, 13, diagnostic.CodeNoThis},
+		{8, 8, diagnostic.CodeNoThis},
+	}
+	if len(diags) != len(expects) {
+		t.Fatalf("\nactual:\n%v\nexpect: %v\n", diags, expects)
+	}
+	for i, e := range expects {
+		if d := diags[i]; d.Line != e.line || d.Column != e.column || d.Code != e.code {
+			t.Errorf("\nactual: %v\nexpect: %v\n", d, e)
+		}
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	src := `class Main {
+  method boolean f(int a, Array b) {
+    var int x;
+    var boolean ok;
+    var char c;
+    let x = c + b[0];
+    let b = 0;
+    let x = true;
+    if (x) { let ok = f(ok, null); }
+    while (~ok) { let b[ok] = null; }
+    return 1;
+  }
+}
+`
+	if err := checkStringWithOptions(t, src, Options{TypeCheck: true}); err != nil {
+		t.Fatalf("type mismatches should only be warnings: %v", err)
+	}
+
+	err := checkStringWithOptions(t, src, Options{TypeCheck: true, TypeErrors: true})
+	diags, ok := err.(diagnostic.List)
+	if !ok {
+		t.Fatalf("expected diagnostics, got %v", err)
+	}
+	expects := []string{
+		"cannot assign boolean to x of type int",
+		"condition has type int, expected boolean",
+		"argument 1 of f has type boolean, expected int",
+		"array index has type boolean, expected int",
+		"cannot return int from f, which returns boolean",
+	}
+	if len(diags) != len(expects) {
+		t.Fatalf("\nactual:\n%v\nexpect: %v\n", diags, expects)
+	}
+	for i, expect := range expects {
+		if diags[i].Message != expect || diags[i].Code != diagnostic.CodeType {
+			t.Errorf("\nactual: %v\nexpect: %v\n", diags[i], expect)
+		}
+	}
+}
+
+func TestCheckCalls(t *testing.T) {
+	index := NewIndex()
+	index.AddClass(jacktest.Parse(t, `class Foo {
+  constructor Foo new(int a) { return this; }
+  method void bar(int a) { return; }
+  function Foo make() { return null; }
+}
+`))
+	err := checkStringWithOptions(t, `class Main {
+  function void main() {
+    var Foo f;
+    let f = Foo.new(1);
+    do f.bar(1, 2);
+    do Foo.bar(1);
+    do f.make();
+    do Foo.missing();
+    do Nope.run();
+    do Output.printInt(1, 2);
+    return;
+  }
+}
+`, Options{Index: index})
+	diags, ok := err.(diagnostic.List)
+	if !ok {
+		t.Fatalf("expected diagnostics, got %v", err)
+	}
+	expects := []string{
+		"Foo.bar expects 1 arguments, found 2",
+		"method Foo.bar must be called on an object",
+		"function Foo.make cannot be called on an object",
+		"undefined subroutine Foo.missing",
+		"undefined class Nope",
+		"Output.printInt expects 1 arguments, found 2",
+	}
+	if len(diags) != len(expects) {
+		t.Fatalf("\nactual:\n%v\nexpect: %v\n", diags, expects)
+	}
+	for i, expect := range expects {
+		if diags[i].Message != expect {
+			t.Errorf("\nactual: %v\nexpect: %v\n", diags[i], expect)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(src string) string {
+		index := NewIndex()
+		index.AddClass(jacktest.Parse(t, src))
+		return index.Fingerprint()
+	}
+	base := fingerprint("class Foo {\n  function int bar(int a) { return a; }\n}\n")
+	if body := fingerprint("class Foo {\n  function int bar(int a) { return a + 1; }\n}\n"); body != base {
+		t.Errorf("a change of body changed the fingerprint")
+	}
+	if params := fingerprint("class Foo {\n  function int bar(char a) { return a; }\n}\n"); params == base {
+		t.Errorf("a change of parameters kept the fingerprint")
+	}
+	if NewIndex().Fingerprint() == base {
+		t.Errorf("a new class kept the fingerprint")
+	}
+	if fingerprint("class Foo {\n}\n") == NewIndex().Fingerprint() {
+		t.Errorf("a new class without subroutines kept the fingerprint")
+	}
+}
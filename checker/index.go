@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// Signature describes a subroutine as seen by its callers.
+type Signature struct {
+	Kind       string // "constructor", "function" or "method"
+	ReturnType string
+	Params     []string // the types of the parameters
+}
+
+// Index holds the signatures of every class of a program, so that calls
+// between classes can be checked. A new Index already describes the Jack OS.
+type Index struct {
+	classes map[string]map[string]*Signature
+}
+
+func NewIndex() *Index {
+	ix := &Index{classes: map[string]map[string]*Signature{}}
+	for className, subs := range osAPI {
+		ix.classes[className] = subs
+	}
+	return ix
+}
+
+// AddClass adds the subroutines of class to the index. A class of the program
+// replaces an OS class of the same name, as when the OS itself is written in
+// Jack.
+func (ix *Index) AddClass(class *ast.Class) {
+	subs := map[string]*Signature{}
+	for _, sub := range class.Subroutines {
+		params := make([]string, len(sub.Params))
+		for i, param := range sub.Params {
+			params[i] = param.Type
+		}
+		subs[sub.Name.Name] = &Signature{
+			Kind:       sub.Kind,
+			ReturnType: sub.ReturnType,
+			Params:     params}
+	}
+	ix.classes[class.Name.Name] = subs
+}
+
+func (ix *Index) HasClass(className string) bool {
+	_, ok := ix.classes[className]
+	return ok
+}
+
+func (ix *Index) Lookup(className, name string) (*Signature, bool) {
+	sig, ok := ix.classes[className][name]
+	return sig, ok
+}
+
+// Fingerprint returns a digest of every class and signature of the index,
+// which changes whenever a class is added or removed, or adds, removes or
+// changes a subroutine.
+func (ix *Index) Fingerprint() string {
+	var lines []string
+	for className, subs := range ix.classes {
+		lines = append(lines, className)
+		for name, sig := range subs {
+			lines = append(lines, fmt.Sprintf("%v.%v %v %v(%v)",
+				className, name, sig.Kind, sig.ReturnType, strings.Join(sig.Params, ",")))
+		}
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// receiverClass returns the class of the subroutine invoked by call and
+// whether it is invoked on an object.
+func (c *Checker) receiverClass(call *ast.CallExpr) (string, bool) {
+	if call.Receiver == "" {
+		return c.className, true
+	}
+	if type_, err := c.st.TypeOf(call.Receiver); err == nil {
+		return type_, true
+	}
+	return call.Receiver, false
+}
+
+// checkCall checks that the subroutine invoked by call exists, is of the
+// right kind and gets as many arguments as it declares. Calls into classes
+// missing from the index are only reported when the whole program is known.
+func (c *Checker) checkCall(call *ast.CallExpr) {
+	className, onObject := c.receiverClass(call)
+	if isPrimitive(className) {
+		c.errorf(call.Pos, diagnostic.CodeCall, "%v of type %v has no subroutine %v", call.Receiver, className, call.Name)
+		return
+	}
+	if !c.index.HasClass(className) {
+		if c.opts.Index != nil {
+			c.errorf(call.Pos, diagnostic.CodeUndefined, "undefined class %v", className)
+		}
+		return
+	}
+	sig, ok := c.index.Lookup(className, call.Name)
+	if !ok {
+		c.errorf(call.Pos, diagnostic.CodeUndefined, "undefined subroutine %v.%v", className, call.Name)
+		return
+	}
+
+	switch {
+	case call.Receiver == "" && c.subKind == "function":
+		// Already reported as a use of this.
+	case call.Receiver == "" && sig.Kind != "method":
+		c.errorf(call.Pos, diagnostic.CodeCall, "%v %v must be called as %v.%v", sig.Kind, call.Name, className, call.Name)
+	case onObject && sig.Kind != "method":
+		c.errorf(call.Pos, diagnostic.CodeCall, "%v %v.%v cannot be called on an object", sig.Kind, className, call.Name)
+	case !onObject && sig.Kind == "method":
+		c.errorf(call.Pos, diagnostic.CodeCall, "method %v.%v must be called on an object", className, call.Name)
+	}
+	if len(call.Args) != len(sig.Params) {
+		c.errorf(call.Pos, diagnostic.CodeCall, "%v.%v expects %v arguments, found %v",
+			className, call.Name, len(sig.Params), len(call.Args))
+	}
+}
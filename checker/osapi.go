@@ -0,0 +1,78 @@
+package checker
+
+func function(returnType string, params ...string) *Signature {
+	return &Signature{Kind: "function", ReturnType: returnType, Params: params}
+}
+
+func method(returnType string, params ...string) *Signature {
+	return &Signature{Kind: "method", ReturnType: returnType, Params: params}
+}
+
+// osAPI describes the classes of the Jack OS.
+var osAPI = map[string]map[string]*Signature{
+	"Math": {
+		"init":     function("void"),
+		"abs":      function("int", "int"),
+		"multiply": function("int", "int", "int"),
+		"divide":   function("int", "int", "int"),
+		"min":      function("int", "int", "int"),
+		"max":      function("int", "int", "int"),
+		"sqrt":     function("int", "int"),
+	},
+	"String": {
+		"new":           {Kind: "constructor", ReturnType: "String", Params: []string{"int"}},
+		"dispose":       method("void"),
+		"length":        method("int"),
+		"charAt":        method("char", "int"),
+		"setCharAt":     method("void", "int", "char"),
+		"appendChar":    method("String", "char"),
+		"eraseLastChar": method("void"),
+		"intValue":      method("int"),
+		"setInt":        method("void", "int"),
+		"backSpace":     function("char"),
+		"doubleQuote":   function("char"),
+		"newLine":       function("char"),
+	},
+	"Array": {
+		"new":     function("Array", "int"),
+		"dispose": method("void"),
+	},
+	"Output": {
+		"init":        function("void"),
+		"moveCursor":  function("void", "int", "int"),
+		"printChar":   function("void", "char"),
+		"printString": function("void", "String"),
+		"printInt":    function("void", "int"),
+		"println":     function("void"),
+		"backSpace":   function("void"),
+	},
+	"Screen": {
+		"init":          function("void"),
+		"clearScreen":   function("void"),
+		"setColor":      function("void", "boolean"),
+		"drawPixel":     function("void", "int", "int"),
+		"drawLine":      function("void", "int", "int", "int", "int"),
+		"drawRectangle": function("void", "int", "int", "int", "int"),
+		"drawCircle":    function("void", "int", "int", "int"),
+	},
+	"Keyboard": {
+		"init":       function("void"),
+		"keyPressed": function("char"),
+		"readChar":   function("char"),
+		"readLine":   function("String", "String"),
+		"readInt":    function("int", "String"),
+	},
+	"Memory": {
+		"init":    function("void"),
+		"peek":    function("int", "int"),
+		"poke":    function("void", "int", "int"),
+		"alloc":   function("Array", "int"),
+		"deAlloc": function("void", "Array"),
+	},
+	"Sys": {
+		"init":  function("void"),
+		"halt":  function("void"),
+		"error": function("void", "int"),
+		"wait":  function("void", "int"),
+	},
+}
@@ -0,0 +1,214 @@
+package checker
+
+import (
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// unknown is the type of expressions whose type cannot be determined, such as
+// array elements and calls into unknown classes. It is compatible with every
+// type.
+const unknown = ""
+
+// nullType is the type of null, which can be assigned to any object.
+const nullType = "null"
+
+// isNumeric reports whether values of type_ can take part in arithmetic.
+// char is an int in Jack, and Array variables are commonly used as raw
+// pointers, so both count as numeric. For the same reason any object can be
+// assigned to an Array.
+func isNumeric(type_ string) bool {
+	return type_ == "int" || type_ == "char" || type_ == "Array"
+}
+
+func isPrimitive(type_ string) bool {
+	return type_ == "int" || type_ == "char" || type_ == "boolean"
+}
+
+func assignable(src, dst string) bool {
+	switch {
+	case src == unknown || dst == unknown || src == dst:
+		return true
+	case isNumeric(src) && isNumeric(dst):
+		return true
+	case src == nullType || dst == "Array":
+		return !isPrimitive(src) && !isPrimitive(dst) && dst != "void"
+	}
+	return false
+}
+
+func (c *Checker) checkLetTypes(let *ast.LetStatement) {
+	if let.Index != nil {
+		c.checkArray(let.Name.Name, let.Name.Pos)
+		c.expectType(let.Index, "int", "array index")
+		c.typeOf(let.Value)
+		return
+	}
+	dst, _ := c.st.TypeOf(let.Name.Name)
+	if src := c.typeOf(let.Value); !assignable(src, dst) {
+		c.typeErrorf(startOf(let.Value), "cannot assign %v to %v of type %v", src, let.Name.Name, dst)
+	}
+}
+
+func (c *Checker) checkCondition(cond ast.Expression) {
+	c.expectType(cond, "boolean", "condition")
+}
+
+func (c *Checker) checkReturnType(ret *ast.ReturnStatement) {
+	name := c.current.Name.Name
+	expect := c.current.ReturnType
+	switch {
+	case ret.Value == nil && expect != "void":
+		c.typeErrorf(ret.Pos, "%v must return a value of type %v", name, expect)
+	case ret.Value != nil && expect == "void":
+		c.typeOf(ret.Value)
+		c.typeErrorf(startOf(ret.Value), "void subroutine %v cannot return a value", name)
+	case ret.Value != nil:
+		if actual := c.typeOf(ret.Value); !assignable(actual, expect) {
+			c.typeErrorf(startOf(ret.Value), "cannot return %v from %v, which returns %v", actual, name, expect)
+		}
+	}
+}
+
+// typeOf returns the type of expr, reporting type errors inside it.
+func (c *Checker) typeOf(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.IntegerConstant:
+		return "int"
+
+	case *ast.StringConstant:
+		return "String"
+
+	case *ast.KeywordConstant:
+		switch e.Keyword {
+		case "true", "false":
+			return "boolean"
+		case "null":
+			return nullType
+		default: // "this"
+			return c.className
+		}
+
+	case *ast.VarExpr:
+		type_, _ := c.st.TypeOf(e.Name)
+		return type_
+
+	case *ast.IndexExpr:
+		c.checkArray(e.Name, e.Pos)
+		c.expectType(e.Index, "int", "array index")
+		return unknown
+
+	case *ast.CallExpr:
+		return c.typeOfCall(e)
+
+	case *ast.ParenExpr:
+		return c.typeOf(e.X)
+
+	case *ast.UnaryExpr:
+		operand := c.typeOf(e.Operand)
+		if e.Op == "-" {
+			c.expectOperand(e.Op, operand, e.Pos)
+			return "int"
+		}
+		// "~" is logical on booleans and bitwise on integers.
+		if operand != "boolean" && operand != unknown && !isNumeric(operand) {
+			c.typeErrorf(e.Pos, "operator ~ expects a boolean or int operand, found %v", operand)
+			return unknown
+		}
+		return operand
+
+	case *ast.BinaryExpr:
+		left := c.typeOf(e.Left)
+		right := c.typeOf(e.Right)
+		switch e.Op {
+		case "+", "-", "*", "/":
+			c.expectOperand(e.Op, left, e.Pos)
+			c.expectOperand(e.Op, right, e.Pos)
+			return "int"
+		case "<", ">":
+			c.expectOperand(e.Op, left, e.Pos)
+			c.expectOperand(e.Op, right, e.Pos)
+			return "boolean"
+		case "=":
+			if !assignable(left, right) && !assignable(right, left) {
+				c.typeErrorf(e.Pos, "cannot compare %v with %v", left, right)
+			}
+			return "boolean"
+		default: // "&", "|"
+			switch {
+			case left == unknown || right == unknown:
+				return unknown
+			case left == "boolean" && right == "boolean":
+				return "boolean"
+			case isNumeric(left) && isNumeric(right):
+				return "int"
+			}
+			c.typeErrorf(e.Pos, "operator %v cannot combine %v with %v", e.Op, left, right)
+			return unknown
+		}
+	}
+	return unknown
+}
+
+// typeOfCall checks the arguments of call against the callee, when it is
+// known, and returns its return type.
+func (c *Checker) typeOfCall(call *ast.CallExpr) string {
+	args := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = c.typeOf(arg)
+	}
+
+	className, _ := c.receiverClass(call)
+	sig, ok := c.index.Lookup(className, call.Name)
+	if !ok {
+		return unknown
+	}
+	for i, param := range sig.Params {
+		if i >= len(args) {
+			break
+		}
+		if !assignable(args[i], param) {
+			c.typeErrorf(startOf(call.Args[i]), "argument %v of %v has type %v, expected %v",
+				i+1, call.Name, args[i], param)
+		}
+	}
+	return sig.ReturnType
+}
+
+func (c *Checker) checkArray(name string, pos ast.Pos) {
+	if type_, err := c.st.TypeOf(name); err == nil && type_ != "Array" {
+		c.typeErrorf(pos, "%v of type %v is not an Array", name, type_)
+	}
+}
+
+func (c *Checker) expectType(expr ast.Expression, expect, what string) {
+	if actual := c.typeOf(expr); !assignable(actual, expect) {
+		c.typeErrorf(startOf(expr), "%v has type %v, expected %v", what, actual, expect)
+	}
+}
+
+func (c *Checker) expectOperand(op, actual string, pos ast.Pos) {
+	if actual != unknown && !isNumeric(actual) {
+		c.typeErrorf(pos, "operator %v expects int operands, found %v", op, actual)
+	}
+}
+
+func (c *Checker) typeErrorf(pos ast.Pos, format string, args ...interface{}) {
+	d := diagnostic.Warningf(c.filename, pos.Line, pos.Column, diagnostic.CodeType, format, args...)
+	if c.opts.TypeErrors || c.opts.WarningsAsErrors {
+		d.Severity = diagnostic.Error
+	}
+	c.diags.Add(d)
+}
+
+// startOf returns the position of the first token of expr. The position of a
+// binary expression itself is that of its operator.
+func startOf(expr ast.Expression) ast.Pos {
+	for {
+		binary, ok := expr.(*ast.BinaryExpr)
+		if !ok {
+			return expr.Position()
+		}
+		expr = binary.Left
+	}
+}
@@ -0,0 +1,313 @@
+package codegen
+
+import (
+	"fmt"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/symboltable"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+// Generator walks an ast.Class and emits VM code for it.
+type Generator struct {
+	vm        *vmwriter.VmWriter
+	st        *symboltable.SymbolTable
+	filename  string
+	className string
+	fold      bool
+	diags     diagnostic.List
+}
+
+var segments = map[string]vmwriter.Segment{
+	"Static":   vmwriter.Static,
+	"Var":      vmwriter.Local,
+	"Field":    vmwriter.This,
+	"Argument": vmwriter.Argument,
+}
+
+func NewGenerator(vm *vmwriter.VmWriter, filename string) *Generator {
+	return &Generator{
+		vm:       vm,
+		st:       symboltable.NewSymbolTable(),
+		filename: filename}
+}
+
+// SetFolding enables the folding of constant expressions and the reduction
+// of multiplications by powers of two into additions, which save calls to
+// the OS. Divisions by powers of two are not reduced and still call
+// Math.divide: the VM has no shift, and halving a value rounding toward zero
+// takes a loop as costly as the division.
+func (g *Generator) SetFolding(fold bool) {
+	g.fold = fold
+}
+
+// GenerateClass emits the VM code of class. Problems found on the way are
+// returned as a diagnostic.List; the emitted code is not usable in that case.
+// With folding enabled, the expressions of class are simplified in place.
+func (g *Generator) GenerateClass(class *ast.Class) error {
+	g.className = class.Name.Name
+	if g.fold {
+		foldClass(class)
+	}
+	for _, dec := range class.ClassVarDecs {
+		for _, name := range dec.Names {
+			g.st.Define(name.Name, dec.Type, dec.Kind)
+		}
+	}
+	for _, sub := range class.Subroutines {
+		g.generateSubroutine(sub)
+	}
+	return g.diags.Err()
+}
+
+func (g *Generator) generateSubroutine(sub *ast.Subroutine) {
+	g.st.StartSubroutine(sub.Kind)
+	for _, param := range sub.Params {
+		g.st.Define(param.Name.Name, param.Type, "arg")
+	}
+	for _, dec := range sub.VarDecs {
+		for _, name := range dec.Names {
+			g.st.Define(name.Name, dec.Type, "var")
+		}
+	}
+
+	numberOfVars, _ := g.st.VarCount("Var")
+	numberOfField, _ := g.st.VarCount("Field")
+	g.vm.WriteFunction(
+		sub.Kind,
+		g.className,
+		sub.Name.Name,
+		numberOfVars,
+		numberOfField)
+
+	g.generateStatements(sub.Statements)
+}
+
+func (g *Generator) generateStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.LetStatement:
+			g.generateLet(s)
+		case *ast.IfStatement:
+			g.generateIf(s)
+		case *ast.WhileStatement:
+			g.generateWhile(s)
+		case *ast.DoStatement:
+			g.generateCall(s.Call)
+			g.vm.WritePop(vmwriter.Temp, 0)
+		case *ast.ReturnStatement:
+			if s.Value != nil {
+				g.generateExpression(s.Value)
+			} else {
+				g.vm.WritePush(vmwriter.Constant, 0)
+			}
+			g.vm.WriteReturn()
+		}
+	}
+}
+
+func (g *Generator) generateLet(let *ast.LetStatement) {
+	segment, index, ok := g.lookup(let.Name.Name, let.Name.Pos)
+	if let.Index == nil {
+		g.generateExpression(let.Value)
+		if ok {
+			g.vm.WritePop(segment, index)
+		}
+		return
+	}
+
+	g.generateExpression(let.Index)
+	if ok {
+		g.vm.WritePush(segment, index)
+	}
+	g.writeArithmetic("+", false, let.Pos)
+	g.generateExpression(let.Value)
+	g.vm.WritePop(vmwriter.Temp, 0)
+	g.vm.WritePop(vmwriter.Pointer, 1)
+	g.vm.WritePush(vmwriter.Temp, 0)
+	g.vm.WritePop(vmwriter.That, 0)
+}
+
+func (g *Generator) generateIf(statement *ast.IfStatement) {
+	trueLabel := fmt.Sprintf("IF_TRUE%v", g.st.IfCount)
+	falseLabel := fmt.Sprintf("IF_FALSE%v", g.st.IfCount)
+	endLabel := fmt.Sprintf("IF_END%v", g.st.IfCount)
+	g.st.IfCount++
+
+	g.generateExpression(statement.Cond)
+	g.vm.WriteIf(trueLabel)
+	g.vm.WriteGoto(falseLabel)
+	g.vm.WriteLabel(trueLabel)
+	g.generateStatements(statement.Then)
+
+	if statement.Else == nil {
+		g.vm.WriteLabel(falseLabel)
+		return
+	}
+	g.vm.WriteGoto(endLabel)
+	g.vm.WriteLabel(falseLabel)
+	g.generateStatements(statement.Else)
+	g.vm.WriteLabel(endLabel)
+}
+
+func (g *Generator) generateWhile(statement *ast.WhileStatement) {
+	whileStart := fmt.Sprintf("WHILE_EXP%v", g.st.WhileCount)
+	whileEnd := fmt.Sprintf("WHILE_END%v", g.st.WhileCount)
+	g.st.WhileCount++
+
+	g.vm.WriteLabel(whileStart)
+	g.generateExpression(statement.Cond)
+	g.writeArithmetic("~", false, statement.Pos)
+	g.vm.WriteIf(whileEnd)
+	g.generateStatements(statement.Body)
+	g.vm.WriteGoto(whileStart)
+	g.vm.WriteLabel(whileEnd)
+}
+
+func (g *Generator) generateExpression(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		if g.fold && g.generateDoublings(e) {
+			return
+		}
+		g.generateExpression(e.Left)
+		g.generateExpression(e.Right)
+		g.writeArithmetic(e.Op, false, e.Pos)
+
+	case *ast.IntegerConstant:
+		g.vm.WritePush(vmwriter.Constant, e.Value)
+
+	case *ast.StringConstant:
+		g.vm.WritePush(vmwriter.Constant, len(e.Value))
+		g.vm.WriteCall("String.new", 1)
+		for _, char := range e.Value {
+			g.vm.WritePush(vmwriter.Constant, int(byte(char)))
+			g.vm.WriteCall("String.appendChar", 2)
+		}
+
+	case *ast.KeywordConstant:
+		switch e.Keyword {
+		case "this":
+			g.vm.WritePush(vmwriter.Pointer, 0)
+		case "true":
+			g.vm.WritePush(vmwriter.Constant, 0)
+			g.writeArithmetic("~", true, e.Pos)
+		case "false", "null":
+			g.vm.WritePush(vmwriter.Constant, 0)
+		}
+
+	case *ast.ParenExpr:
+		g.generateExpression(e.X)
+
+	case *ast.UnaryExpr:
+		g.generateExpression(e.Operand)
+		g.writeArithmetic(e.Op, true, e.Pos)
+
+	case *ast.VarExpr:
+		if segment, index, ok := g.lookup(e.Name, e.Pos); ok {
+			g.vm.WritePush(segment, index)
+		}
+
+	case *ast.IndexExpr:
+		g.generateExpression(e.Index)
+		if segment, index, ok := g.lookup(e.Name, e.Pos); ok {
+			g.vm.WritePush(segment, index)
+		}
+		g.writeArithmetic("+", true, e.Pos)
+		g.vm.WritePop(vmwriter.Pointer, 1)
+		g.vm.WritePush(vmwriter.That, 0)
+
+	case *ast.CallExpr:
+		g.generateCall(e)
+	}
+}
+
+func (g *Generator) generateCall(call *ast.CallExpr) {
+	switch {
+	// Method in this class
+	case call.Receiver == "":
+		g.vm.WritePush(vmwriter.Pointer, 0)
+		g.generateExpressions(call.Args)
+		g.vm.WriteCall(
+			fmt.Sprintf("%v.%v", g.className, call.Name),
+			len(call.Args)+1)
+
+	// Method of another object
+	case g.isInstanceName(call.Receiver):
+		typeOfReceiver, _ := g.st.TypeOf(call.Receiver)
+		segment, index, _ := g.lookup(call.Receiver, call.Pos)
+		g.vm.WritePush(segment, index)
+		g.generateExpressions(call.Args)
+		g.vm.WriteCall(
+			fmt.Sprintf("%v.%v", typeOfReceiver, call.Name),
+			len(call.Args)+1)
+
+	// Function or constructor of a class
+	default:
+		g.generateExpressions(call.Args)
+		g.vm.WriteCall(
+			fmt.Sprintf("%v.%v", call.Receiver, call.Name),
+			len(call.Args))
+	}
+}
+
+func (g *Generator) generateExpressions(exprs []ast.Expression) {
+	for _, expr := range exprs {
+		g.generateExpression(expr)
+	}
+}
+
+// lookup returns the segment and index of a variable, reporting it if it is
+// not declared.
+func (g *Generator) lookup(name string, pos ast.Pos) (vmwriter.Segment, int, bool) {
+	kind, err := g.st.KindOf(name)
+	if err != nil {
+		g.errorf(pos, diagnostic.CodeUndefined, "undefined variable %v", name)
+		return 0, 0, false
+	}
+	index, _ := g.st.IndexOf(name)
+	return segments[kind], index, true
+}
+
+func (g *Generator) isInstanceName(id string) bool {
+	_, err := g.st.TypeOf(id)
+	return err == nil
+}
+
+// generateDoublings generates x * 2^k, or 2^k * x, as x doubled k times
+// through temp 1, which is cheaper than calling Math.multiply whatever x is.
+// Multiplications of small powers of two by a variable are already added up
+// by the folding. It reports whether e was such a multiplication.
+func (g *Generator) generateDoublings(e *ast.BinaryExpr) bool {
+	if e.Op != "*" {
+		return false
+	}
+	x, factor := e.Left, 0
+	if value, ok := constant(e.Right); ok {
+		factor = int(value)
+	} else if value, ok := constant(e.Left); ok {
+		x, factor = e.Right, int(value)
+	}
+	if factor <= 1 || factor&(factor-1) != 0 {
+		return false
+	}
+	g.generateExpression(x)
+	for ; factor > 1; factor /= 2 {
+		g.vm.WritePop(vmwriter.Temp, 1)
+		g.vm.WritePush(vmwriter.Temp, 1)
+		g.vm.WritePush(vmwriter.Temp, 1)
+		g.writeArithmetic("+", false, e.Pos)
+	}
+	return true
+}
+
+func (g *Generator) writeArithmetic(command string, inTerm bool, pos ast.Pos) {
+	if err := g.vm.WriteArithmetic(command, inTerm); err != nil {
+		g.errorf(pos, diagnostic.CodeInternal, "%v", err)
+	}
+}
+
+func (g *Generator) errorf(pos ast.Pos, code, format string, args ...interface{}) {
+	g.diags.Add(diagnostic.Errorf(g.filename, pos.Line, pos.Column, code, format, args...))
+}
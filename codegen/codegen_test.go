@@ -0,0 +1,163 @@
+package codegen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/internal/jacktest"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+func generateString(t *testing.T, src string) (string, error) {
+	return generateStringWithFolding(t, src, false)
+}
+
+func generateStringWithFolding(t *testing.T, src string, fold bool) (string, error) {
+	class := jacktest.Parse(t, src)
+	vm := vmwriter.NewVmWriter(nil)
+	g := NewGenerator(vm, "Main.jack")
+	g.SetFolding(fold)
+	err := g.GenerateClass(class)
+	var out bytes.Buffer
+	vmwriter.Write(&out, vm.Instructions())
+	return out.String(), err
+}
+
+func TestGenerateClass(t *testing.T) {
+	actual, err := generateString(t, `class Main {
+  field Array a;
+  method void set(int i) {
+    let a[i] = i;
+    while (i > 0) {
+      let i = i - 1;
+    }
+    return;
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `function Main.set 0
+push argument 0
+pop pointer 0
+push argument 1
+push this 0
+add
+push argument 1
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+label WHILE_EXP0
+push argument 1
+push constant 0
+gt
+not
+if-goto WHILE_END0
+push argument 1
+push constant 1
+sub
+pop argument 1
+goto WHILE_EXP0
+label WHILE_END0
+push constant 0
+return
+`
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestGenerateClassReportsUndefinedVariable(t *testing.T) {
+	_, err := generateString(t, "class Main {\n  function void main() {\n    let x = 1;\n    return;\n  }\n}\n")
+	diags, ok := err.(diagnostic.List)
+	if !ok || len(diags) != 1 || diags[0].Code != diagnostic.CodeUndefined || diags[0].Line != 3 {
+		t.Errorf("unexpected diagnostics: %v", err)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	actual, err := generateStringWithFolding(t, `class Main {
+  function int main(int x) {
+    let x = 1 + (2 * 3);
+    let x = (-200) * 200;
+    let x = (~32767) - 1;
+    let x = ~(3 < 4);
+    let x = (7 / 0) + (x * 1);
+    let x = ((x + 1) + 2) - 5;
+    let x = 4 * x;
+    let x = (x * 0) + (Main.main(x) * 0);
+    let x = 2 * Main.main(x);
+    return (x * 16) / 2;
+  }
+}
+`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `function Main.main 0
+push constant 7
+pop argument 0
+push constant 25536
+pop argument 0
+push constant 32767
+pop argument 0
+push constant 0
+pop argument 0
+push constant 7
+push constant 0
+call Math.divide 2
+push argument 0
+add
+pop argument 0
+push argument 0
+push constant 2
+sub
+pop argument 0
+push argument 0
+push argument 0
+add
+push argument 0
+add
+push argument 0
+add
+pop argument 0
+push argument 0
+call Main.main 1
+push constant 0
+call Math.multiply 2
+pop argument 0
+push argument 0
+call Main.main 1
+pop temp 1
+push temp 1
+push temp 1
+add
+pop argument 0
+push argument 0
+pop temp 1
+push temp 1
+push temp 1
+add
+pop temp 1
+push temp 1
+push temp 1
+add
+pop temp 1
+push temp 1
+push temp 1
+add
+pop temp 1
+push temp 1
+push temp 1
+add
+push constant 2
+call Math.divide 2
+return
+`
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
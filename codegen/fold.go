@@ -0,0 +1,243 @@
+package codegen
+
+import (
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+)
+
+// maxDoublings bounds the rewriting of x * 2^k into x + x + ... + x for a
+// variable x: past 2^3, doubling x through a temporary, as the code generator
+// does for the other multiplications by powers of two, is shorter.
+const maxDoublings = 3
+
+// foldClass replaces in place the expressions of class by simpler ones with
+// the same value. Constant sub-expressions are evaluated the way the VM would
+// evaluate them, on 16 bits in two's complement. Only sub-expressions are
+// folded: as Jack has no precedence, the 2 * 3 of x + 2 * 3 is not one.
+func foldClass(class *ast.Class) {
+	for _, sub := range class.Subroutines {
+		foldStatements(sub.Statements)
+	}
+}
+
+func foldStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.LetStatement:
+			if s.Index != nil {
+				s.Index = fold(s.Index)
+			}
+			s.Value = fold(s.Value)
+		case *ast.IfStatement:
+			s.Cond = fold(s.Cond)
+			foldStatements(s.Then)
+			foldStatements(s.Else)
+		case *ast.WhileStatement:
+			s.Cond = fold(s.Cond)
+			foldStatements(s.Body)
+		case *ast.DoStatement:
+			fold(s.Call)
+		case *ast.ReturnStatement:
+			if s.Value != nil {
+				s.Value = fold(s.Value)
+			}
+		}
+	}
+}
+
+func fold(expr ast.Expression) ast.Expression {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		e.X = fold(e.X)
+		if _, ok := constant(e.X); ok {
+			return e.X
+		}
+
+	case *ast.UnaryExpr:
+		e.Operand = fold(e.Operand)
+		if value, ok := constant(e); ok {
+			return makeConstant(value, e.Pos)
+		}
+
+	case *ast.BinaryExpr:
+		e.Left = fold(e.Left)
+		e.Right = fold(e.Right)
+		return simplify(e)
+
+	case *ast.IndexExpr:
+		e.Index = fold(e.Index)
+
+	case *ast.CallExpr:
+		for i, arg := range e.Args {
+			e.Args[i] = fold(arg)
+		}
+	}
+	return expr
+}
+
+// simplify folds a binary expression whose operands are already folded.
+func simplify(e *ast.BinaryExpr) ast.Expression {
+	left, leftConstant := constant(e.Left)
+	right, rightConstant := constant(e.Right)
+
+	switch {
+	case leftConstant && rightConstant:
+		if e.Op == "/" && right == 0 {
+			// Left for Math.divide to report at run time.
+			return e
+		}
+		return evaluate(e.Op, left, right, e.Pos)
+
+	case rightConstant && (right == 0 && (e.Op == "+" || e.Op == "-" || e.Op == "|") ||
+		right == 1 && (e.Op == "*" || e.Op == "/")):
+		return e.Left
+
+	case leftConstant && (left == 0 && (e.Op == "+" || e.Op == "|") || left == 1 && e.Op == "*"):
+		return e.Right
+
+	case rightConstant && right == 0 && (e.Op == "*" || e.Op == "&") && isPure(e.Left),
+		leftConstant && left == 0 && (e.Op == "*" || e.Op == "&") && isPure(e.Right):
+		return makeConstant(0, e.Pos)
+
+	case rightConstant && e.Op == "*":
+		return double(e, e.Left, right)
+
+	case leftConstant && e.Op == "*":
+		return double(e, e.Right, left)
+
+	case rightConstant && (e.Op == "+" || e.Op == "-"):
+		return reassociate(e, right)
+	}
+	return e
+}
+
+// double rewrites x * 2^k into x + x + ... + x when x is a variable, which
+// can be read repeatedly without changing the result.
+func double(e *ast.BinaryExpr, x ast.Expression, factor int16) ast.Expression {
+	variable, ok := x.(*ast.VarExpr)
+	if !ok || factor <= 1 || factor > 1<<maxDoublings || factor&(factor-1) != 0 {
+		return e
+	}
+	var sum ast.Expression = variable
+	for i := int16(1); i < factor; i++ {
+		sum = &ast.BinaryExpr{Pos: e.Pos, Op: "+", Left: sum, Right: &ast.VarExpr{Pos: variable.Pos, Name: variable.Name}}
+	}
+	return sum
+}
+
+// reassociate folds (x + c1) + c2 into x + (c1 + c2), which is exact in
+// two's complement, and likewise with subtractions.
+func reassociate(e *ast.BinaryExpr, right int16) ast.Expression {
+	left := e.Left
+	for paren, ok := left.(*ast.ParenExpr); ok; paren, ok = left.(*ast.ParenExpr) {
+		left = paren.X
+	}
+	inner, ok := left.(*ast.BinaryExpr)
+	if !ok || inner.Op != "+" && inner.Op != "-" {
+		return e
+	}
+	innerRight, ok := constant(inner.Right)
+	if !ok {
+		return e
+	}
+	if inner.Op == "-" {
+		innerRight = -innerRight
+	}
+	if e.Op == "-" {
+		right = -right
+	}
+	sum := innerRight + right
+	switch {
+	case sum == 0:
+		return inner.Left
+	case sum > 0:
+		return &ast.BinaryExpr{Pos: e.Pos, Op: "+", Left: inner.Left, Right: makeConstant(sum, e.Pos)}
+	case sum != -32768:
+		return &ast.BinaryExpr{Pos: e.Pos, Op: "-", Left: inner.Left, Right: makeConstant(-sum, e.Pos)}
+	}
+	return &ast.BinaryExpr{Pos: e.Pos, Op: "+", Left: inner.Left, Right: makeConstant(sum, e.Pos)}
+}
+
+func evaluate(op string, x, y int16, pos ast.Pos) ast.Expression {
+	switch op {
+	case "+":
+		return makeConstant(x+y, pos)
+	case "-":
+		return makeConstant(x-y, pos)
+	case "*":
+		return makeConstant(x*y, pos)
+	case "/":
+		return makeConstant(x/y, pos)
+	case "&":
+		return makeConstant(x&y, pos)
+	case "|":
+		return makeConstant(x|y, pos)
+	case "<":
+		return makeBoolean(x < y, pos)
+	case ">":
+		return makeBoolean(x > y, pos)
+	default: // "="
+		return makeBoolean(x == y, pos)
+	}
+}
+
+// constant returns the value of expr if it is known at compile time.
+func constant(expr ast.Expression) (int16, bool) {
+	switch e := expr.(type) {
+	case *ast.IntegerConstant:
+		return int16(e.Value), true
+	case *ast.KeywordConstant:
+		switch e.Keyword {
+		case "true":
+			return -1, true
+		case "false", "null":
+			return 0, true
+		}
+	case *ast.ParenExpr:
+		return constant(e.X)
+	case *ast.UnaryExpr:
+		value, ok := constant(e.Operand)
+		if e.Op == "-" {
+			return -value, ok
+		}
+		return ^value, ok
+	}
+	return 0, false
+}
+
+// makeConstant returns an expression for value made of a constant the VM can
+// push, negated if need be.
+func makeConstant(value int16, pos ast.Pos) ast.Expression {
+	switch {
+	case value >= 0:
+		return &ast.IntegerConstant{Pos: pos, Value: int(value)}
+	case value == -32768:
+		return &ast.UnaryExpr{Pos: pos, Op: "~", Operand: &ast.IntegerConstant{Pos: pos, Value: 32767}}
+	}
+	return &ast.UnaryExpr{Pos: pos, Op: "-", Operand: &ast.IntegerConstant{Pos: pos, Value: int(-value)}}
+}
+
+func makeBoolean(value bool, pos ast.Pos) ast.Expression {
+	if value {
+		return &ast.KeywordConstant{Pos: pos, Keyword: "true"}
+	}
+	return &ast.KeywordConstant{Pos: pos, Keyword: "false"}
+}
+
+// isPure reports whether evaluating expr has no effect besides its value, so
+// that it can be left out.
+func isPure(expr ast.Expression) bool {
+	pure := true
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch e := node.(type) {
+		case *ast.CallExpr, *ast.StringConstant:
+			pure = false
+		case *ast.BinaryExpr:
+			// Math.divide fails on a division by zero.
+			if e.Op == "/" {
+				pure = false
+			}
+		}
+		return pure
+	})
+	return pure
+}
@@ -1,18 +1,89 @@
 package compilationengine
 
 import (
+	"bytes"
 	"os"
 	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
 )
 
 func TestComileTerm(t *testing.T) {
-	inputFile, _ := os.Open("Script.jack")
+	inputFile, _ := os.Open("../testcases/Seven/Main.jack")
 	defer inputFile.Close()
-	debugFile, _ := os.Create("outputOfTestCompileTerms.xml")
-	defer debugFile.Close()
-	outputFile, _ := os.Create("Script_.vm")
-	defer outputFile.Close()
+	var output, debug bytes.Buffer
+
+	cmplEngn := NewCompilationEngine(inputFile, &output, &debug)
+	if err := cmplEngn.CompileClass(); err != nil {
+		t.Error(err)
+	}
+}
+
+func compileString(src string) error {
+	_, err := CompileSource("Main.jack", src)
+	return err
+}
+
+func TestCompileSource(t *testing.T) {
+	actual, err := CompileSource("Main.jack", "class Main {\n  function int main() {\n    return 1 + 2;\n  }\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "function Main.main 0\npush constant 1\npush constant 2\nadd\nreturn\n"
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
 
-	cmplEngn := NewCompilationEngine(inputFile, outputFile, debugFile)
-	cmplEngn.CompileClass()
+	_, err = CompileSource("Broken.jack", "class Broken {\n  function void f() {\n    let x = 1;\n    return;\n  }\n}\n")
+	diags, ok := err.(diagnostic.List)
+	if !ok || len(diags) != 1 || diags[0].File != "Broken.jack" || diags[0].Line != 3 {
+		t.Errorf("unexpected diagnostics: %v", err)
+	}
+}
+
+func TestCompileClassReportsDiagnostic(t *testing.T) {
+	err := compileString("class Main {\n  function void main() {\n    let = 1;\n    return;\n  }\n}\n")
+	diags, ok := err.(diagnostic.List)
+	if !ok || len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got %v", err)
+	}
+	if d := diags[0]; d.Line != 3 || d.Column != 9 || d.Code != diagnostic.CodeSyntax {
+		t.Errorf("unexpected diagnostic: %v", d)
+	}
+}
+
+func TestCompileClassRecoversFromErrors(t *testing.T) {
+	src := `class Main {
+  field int a b;
+  function void main() {
+    var int y;
+    let y = ;
+    y = 3;
+    if (y { let y = 1; }
+    while (y) { let y = y - 1 }
+    return;
+  }
+  method void f(int) {
+    return;
+  }
+  method void g() {
+    do Output.printInt(1 2);
+    return;
+  }
+}
+`
+	err := compileString(src)
+	diags, ok := err.(diagnostic.List)
+	if !ok {
+		t.Fatalf("expected diagnostics, got %v", err)
+	}
+	expects := []int{2, 5, 6, 7, 8, 11, 15}
+	if len(diags) != len(expects) {
+		t.Fatalf("\nactual: %v\nexpect lines: %v\n", diags, expects)
+	}
+	for i, line := range expects {
+		if diags[i].Line != line {
+			t.Errorf("\nactual: %v\nexpect line: %v\n", diags[i], line)
+		}
+	}
 }
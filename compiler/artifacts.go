@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/jacktokenizer"
+	"github.com/jinpei0908/rewriting-JackCompiler/symboltable"
+	"github.com/jinpei0908/rewriting-JackCompiler/xmlwriter"
+)
+
+var tokenKinds = map[jacktokenizer.TokenTypes]string{
+	jacktokenizer.Keyword:     "keyword",
+	jacktokenizer.Symbol:      "symbol",
+	jacktokenizer.Identifier:  "identifier",
+	jacktokenizer.IntConst:    "integerConstant",
+	jacktokenizer.StringConst: "stringConstant",
+}
+
+// writeTokens writes one token per line as "line:column kind text".
+func writeTokens(w io.Writer, tokens []jacktokenizer.Token) error {
+	bw := bufio.NewWriter(w)
+	for _, token := range tokens {
+		fmt.Fprintf(bw, "%v:%v %v %v\n", token.Line, token.Column, tokenKinds[token.Kind], token.Text)
+	}
+	return bw.Flush()
+}
+
+// writeTokensXML writes the tokens in the xxxT.xml format of project 10 of
+// nand2tetris: one element per token, named after its kind, inside a
+// <tokens> element.
+func writeTokensXML(w io.Writer, tokens []jacktokenizer.Token) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "<tokens>")
+	for _, token := range tokens {
+		kind := tokenKinds[token.Kind]
+		fmt.Fprintf(bw, "<%v> %v </%v>\n", kind, xmlwriter.Escape(token.Text), kind)
+	}
+	fmt.Fprintln(bw, "</tokens>")
+	return bw.Flush()
+}
+
+// symbolSegments are the VM segments of the kinds of the symbol table.
+var symbolSegments = map[string]string{
+	"Static":   "static",
+	"Field":    "this",
+	"Var":      "local",
+	"Argument": "argument",
+}
+
+// writeSymbols writes the symbol table of class, then those of its
+// subroutines, one symbol per line as "kind type name index", the kinds
+// being the VM segments the symbols live in. The symbols are defined in a
+// symboltable.SymbolTable as the code generator defines them, so that the
+// kinds and indexes listed are those of the generated code.
+func writeSymbols(w io.Writer, class *ast.Class) error {
+	bw := bufio.NewWriter(w)
+	st := symboltable.NewSymbolTable()
+	list := func(name string) {
+		kind, _ := st.KindOf(name)
+		type_, _ := st.TypeOf(name)
+		index, _ := st.IndexOf(name)
+		if type_ == "" { // the this of methods
+			type_ = class.Name.Name
+		}
+		fmt.Fprintf(bw, "  %v %v %v %v\n", symbolSegments[kind], type_, name, index)
+	}
+
+	fmt.Fprintf(bw, "class %v\n", class.Name.Name)
+	for _, dec := range class.ClassVarDecs {
+		for _, name := range dec.Names {
+			st.Define(name.Name, dec.Type, dec.Kind)
+			list(name.Name)
+		}
+	}
+	for _, sub := range class.Subroutines {
+		fmt.Fprintf(bw, "%v %v %v.%v\n", sub.Kind, sub.ReturnType, class.Name.Name, sub.Name.Name)
+		st.StartSubroutine(sub.Kind)
+		if sub.Kind == "method" {
+			list("this")
+		}
+		for _, param := range sub.Params {
+			st.Define(param.Name.Name, param.Type, "arg")
+			list(param.Name.Name)
+		}
+		for _, dec := range sub.VarDecs {
+			for _, name := range dec.Names {
+				st.Define(name.Name, dec.Type, "var")
+				list(name.Name)
+			}
+		}
+	}
+	return bw.Flush()
+}
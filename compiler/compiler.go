@@ -0,0 +1,233 @@
+// Package compiler is the library interface of the Jack compiler: it compiles
+// Jack classes read from any io.Reader into VM code.
+//
+//	c := compiler.New(compiler.Options{TypeCheck: true})
+//	vm, diags, err := c.CompileSource("Main.jack", src)
+//
+// The classes of a program are checked against each other once they are
+// declared with AddClass; calls to the OS are always checked.
+package compiler
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/checker"
+	"github.com/jinpei0908/rewriting-JackCompiler/compilationengine"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/jacktokenizer"
+	"github.com/jinpei0908/rewriting-JackCompiler/optimizer"
+)
+
+// Version identifies the code the compiler generates. It changes whenever
+// the output for a given input may change, so that cached outputs of former
+// versions are not reused.
+const Version = "1.2.0"
+
+// Options select the optional analyses and transformations of the compiler.
+// The zero value compiles the way the reference compiler does.
+type Options struct {
+	// TypeCheck checks the types of expressions, assignments, calls and
+	// returns. Mismatches are warnings unless TypeErrors is set.
+	TypeCheck  bool
+	TypeErrors bool
+	// WarningsAsErrors reports every warning as an error.
+	WarningsAsErrors bool
+	// Optimize folds constant expressions and simplifies the generated code.
+	Optimize bool
+	// IdentifierInfo follows each identifier of the XML syntax tree with an
+	// <identifierInfo> element describing its symbol, which extends the
+	// standard format of the course.
+	IdentifierInfo bool
+}
+
+// Stage is how far the compilation of a class goes.
+type Stage int
+
+const (
+	// Tokenize only reads the tokens of the class.
+	Tokenize Stage = iota
+	// Parse builds the syntax tree of the class.
+	Parse
+	// Check runs the semantic checker on the syntax tree.
+	Check
+	// Generate compiles the class into VM code.
+	Generate
+)
+
+// Outputs are the writers of the artifacts of a class. The artifacts whose
+// writer is nil are not produced, and neither are those of the stages that
+// are not reached.
+type Outputs struct {
+	// VM receives the VM code.
+	VM io.Writer
+	// XML receives the syntax tree.
+	XML io.Writer
+	// Tokens receives the tokens, one per line.
+	Tokens io.Writer
+	// TokensXML receives the tokens in the xxxT.xml format of the course.
+	TokensXML io.Writer
+	// Symbols receives the symbol tables of the class and its subroutines.
+	Symbols io.Writer
+}
+
+// Compiler compiles the classes of one program. Once the classes are added,
+// the compilation methods may be called concurrently.
+type Compiler struct {
+	opts  Options
+	index *checker.Index
+}
+
+func New(opts Options) *Compiler {
+	return &Compiler{opts: opts, index: checker.NewIndex()}
+}
+
+// Options returns the options the compiler was created with.
+func (c *Compiler) Options() Options {
+	return c.opts
+}
+
+// SignatureDigest returns a digest of the signatures of every class added,
+// which changes when a class adds, removes or changes a subroutine. A class
+// compiled with the same options, source and signature digest compiles to
+// the same outputs.
+func (c *Compiler) SignatureDigest() string {
+	return c.index.Fingerprint()
+}
+
+// AddClass declares the class read from r as part of the program, so that
+// the calls to its subroutines are checked. Errors in the class are reported
+// when it is compiled, not here.
+func (c *Compiler) AddClass(name string, r io.Reader) {
+	compilationengine.IndexSource(name, r, c.index)
+}
+
+// AddFile is AddClass for the named file.
+func (c *Compiler) AddFile(name string) error {
+	return compilationengine.IndexFile(name, c.index)
+}
+
+// Compile compiles the class read from r, writing its VM code to vm and,
+// unless xml is nil, its syntax tree to xml. name labels the diagnostics. It
+// returns every diagnostic found, warnings included; err is the errors among
+// them as a diagnostic.List, or an I/O error. No VM code is written for a
+// class with errors.
+func (c *Compiler) Compile(name string, r io.Reader, vm, xml io.Writer) (diags diagnostic.List, err error) {
+	return c.CompileStage(name, r, Generate, Outputs{VM: vm, XML: xml})
+}
+
+// CompileStage takes the class read from r up to stage, writing the
+// artifacts it has produced on the way to out. It returns the diagnostics as
+// Compile does.
+func (c *Compiler) CompileStage(name string, r io.Reader, stage Stage, out Outputs) (diags diagnostic.List, err error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if out.Tokens != nil || out.TokensXML != nil || stage == Tokenize {
+		tokens, err := ScanTokens(name, bytes.NewReader(src))
+		if list, ok := err.(diagnostic.List); ok {
+			return list, list
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out.Tokens != nil {
+			if err := writeTokens(out.Tokens, tokens); err != nil {
+				return nil, err
+			}
+		}
+		if out.TokensXML != nil {
+			if err := writeTokensXML(out.TokensXML, tokens); err != nil {
+				return nil, err
+			}
+		}
+		if stage == Tokenize {
+			return nil, nil
+		}
+	}
+
+	xml, vm := out.XML, out.VM
+	if xml == nil {
+		xml = io.Discard
+	}
+	if vm == nil {
+		vm = io.Discard
+	}
+	ce := compilationengine.NewCompilationEngine(jacktokenizer.NamedReader{Reader: bytes.NewReader(src), Filename: name}, vm, xml)
+	ce.SetCheckerOptions(checker.Options{
+		TypeCheck:        c.opts.TypeCheck || c.opts.TypeErrors,
+		TypeErrors:       c.opts.TypeErrors,
+		WarningsAsErrors: c.opts.WarningsAsErrors,
+		Index:            c.index})
+	ce.SetFolding(c.opts.Optimize)
+	ce.SetIdentifierInfo(c.opts.IdentifierInfo)
+	if c.opts.Optimize {
+		ce.SetOptimizer(optimizer.Optimize)
+	}
+
+	switch stage {
+	case Parse:
+		_, err = ce.ParseClass()
+	case Check:
+		_, err = ce.CheckClass()
+	default:
+		err = ce.CompileClass()
+	}
+	if class := ce.Class(); class != nil && out.Symbols != nil {
+		if err := writeSymbols(out.Symbols, class); err != nil {
+			return ce.Diagnostics(), err
+		}
+	}
+	return ce.Diagnostics(), err
+}
+
+// ScanTokens returns the tokens of the class read from r, as the Tokenize
+// stage reads them. name labels the diagnostics; a lexical error is returned
+// as a diagnostic.List.
+func ScanTokens(name string, r io.Reader) ([]jacktokenizer.Token, error) {
+	scanner := jacktokenizer.NewScanner(r)
+	scanner.Filename = name
+	tokens, err := scanner.ScanAll()
+	if d, ok := err.(*diagnostic.Diagnostic); ok {
+		return nil, diagnostic.List{d}
+	}
+	return tokens, err
+}
+
+// CompileSource compiles the class src and returns its VM code.
+func (c *Compiler) CompileSource(name, src string) (vm string, diags diagnostic.List, err error) {
+	var out strings.Builder
+	diags, err = c.Compile(name, strings.NewReader(src), &out, nil)
+	if err != nil {
+		return "", diags, err
+	}
+	return out.String(), diags, nil
+}
+
+// CompileFile compiles the named Jack file into the named VM file and, unless
+// xmlName is empty, the named XML file.
+func (c *Compiler) CompileFile(jackName, vmName, xmlName string) (diagnostic.List, error) {
+	in, err := os.Open(jackName)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := os.Create(vmName)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	var xml io.Writer
+	if xmlName != "" {
+		xmlFile, err := os.Create(xmlName)
+		if err != nil {
+			return nil, err
+		}
+		defer xmlFile.Close()
+		xml = xmlFile
+	}
+	return c.Compile(jackName, in, out, xml)
+}
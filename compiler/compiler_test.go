@@ -0,0 +1,165 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+func TestCompileSource(t *testing.T) {
+	c := New(Options{Optimize: true})
+	actual, diags, err := c.CompileSource("Main.jack", `class Main {
+  function int main() {
+    return 2 * 3;
+  }
+}
+`)
+	if err != nil || len(diags) != 0 {
+		t.Fatal(err, diags)
+	}
+	expect := "function Main.main 0\npush constant 6\nreturn\n"
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestCompileChecksProgram(t *testing.T) {
+	c := New(Options{TypeCheck: true})
+	c.AddClass("Point.jack", strings.NewReader(`class Point {
+  function int origin() {
+    return 0;
+  }
+}
+`))
+	src := `class Main {
+  function void main() {
+    var boolean b;
+    let b = 1;
+    do Point.origin(1);
+    return;
+  }
+}
+`
+	_, diags, err := c.CompileSource("Main.jack", src)
+	if err == nil || len(diags) != 2 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if diags[0].Severity != diagnostic.Warning || diags[0].Code != diagnostic.CodeType ||
+		diags[1].Severity != diagnostic.Error || diags[1].Code != diagnostic.CodeCall {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+}
+
+func TestCompileStage(t *testing.T) {
+	src := `class Main {
+  field int x;
+  method void set(int v) {
+    var boolean b;
+    let x = v;
+    return;
+  }
+}
+`
+	var tokens, symbols, vm strings.Builder
+	_, err := New(Options{}).CompileStage("Main.jack", strings.NewReader(src), Check, Outputs{VM: &vm, Tokens: &tokens, Symbols: &symbols})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Len() != 0 {
+		t.Errorf("VM code written at the check stage:\n%v", vm.String())
+	}
+	if !strings.HasPrefix(tokens.String(), "1:1 keyword class\n1:7 identifier Main\n1:12 symbol {\n") {
+		t.Errorf("unexpected tokens:\n%v", tokens.String())
+	}
+	expect := `class Main
+  this int x 0
+method void Main.set
+  argument Main this 0
+  argument int v 1
+  local boolean b 0
+`
+	if actual := symbols.String(); actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestScanTokens(t *testing.T) {
+	tokens, err := ScanTokens("Main.jack", strings.NewReader("class Main {\n  // comment\n  field int x; }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, token := range tokens {
+		actual = append(actual, fmt.Sprintf("%v:%v %v", token.Line, token.Column, token.Text))
+	}
+	expect := []string{"1:1 class", "1:7 Main", "1:12 {", "3:3 field", "3:9 int", "3:13 x", "3:14 ;", "3:16 }"}
+	if strings.Join(actual, ",") != strings.Join(expect, ",") {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+
+	_, err = ScanTokens("Main.jack", strings.NewReader("class Main { let x = \"open"))
+	if list, ok := err.(diagnostic.List); !ok || list[0].Code != diagnostic.CodeLexical {
+		t.Errorf("expected a lexical error, got %v", err)
+	}
+}
+
+func TestTokensXML(t *testing.T) {
+	var txml strings.Builder
+	src := "class Main {\n  function void main() { do Output.printString(\"a<b\"); return; }\n}\n"
+	_, err := New(Options{}).CompileStage("Main.jack", strings.NewReader(src), Tokenize, Outputs{TokensXML: &txml})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `<tokens>
+<keyword> class </keyword>
+<identifier> Main </identifier>
+<symbol> { </symbol>
+<keyword> function </keyword>
+<keyword> void </keyword>
+<identifier> main </identifier>
+<symbol> ( </symbol>
+<symbol> ) </symbol>
+<symbol> { </symbol>
+<keyword> do </keyword>
+<identifier> Output </identifier>
+<symbol> . </symbol>
+<identifier> printString </identifier>
+<symbol> ( </symbol>
+<stringConstant> a&lt;b </stringConstant>
+<symbol> ) </symbol>
+<symbol> ; </symbol>
+<keyword> return </keyword>
+<symbol> ; </symbol>
+<symbol> } </symbol>
+<symbol> } </symbol>
+</tokens>
+`
+	if actual := txml.String(); actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestCompileConcurrently(t *testing.T) {
+	c := New(Options{TypeCheck: true, Optimize: true})
+	c.AddClass("Point.jack", strings.NewReader("class Point {\n  function int origin() {\n    return 0;\n  }\n}\n"))
+	src := "class Main {\n  function int main() {\n    return Point.origin() + (4 * 2);\n  }\n}\n"
+	expect, _, err := c.CompileSource("Main.jack", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan string)
+	for i := 0; i < 8; i++ {
+		go func() {
+			vm, _, _ := c.CompileSource("Main.jack", src)
+			results <- vm
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if actual := <-results; actual != expect {
+			t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+// Codes classify diagnostics so that tools can filter them without parsing
+// the message.
+const (
+	CodeLexical   = "lexical"
+	CodeSyntax    = "syntax"
+	CodeUndefined = "undefined"
+	CodeDuplicate = "duplicate"
+	CodeNoThis    = "no-this"
+	CodeType      = "type"
+	CodeCall      = "call"
+	CodeInternal  = "internal"
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic is a problem found in a source file. It implements error so it
+// can be returned directly from the compiler.
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	Line     int
+	Column   int
+	Message  string
+	Code     string
+}
+
+func Errorf(file string, line, column int, code, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: Error,
+		File:     file,
+		Line:     line,
+		Column:   column,
+		Message:  fmt.Sprintf(format, args...),
+		Code:     code}
+}
+
+func Warningf(file string, line, column int, code, format string, args ...interface{}) *Diagnostic {
+	d := Errorf(file, line, column, code, format, args...)
+	d.Severity = Warning
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%v:%v:%v: %v: %v [%v]",
+		d.File, d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+// List is an ordered collection of diagnostics. A List containing at least
+// one error is itself an error.
+type List []*Diagnostic
+
+func (l *List) Add(d *Diagnostic) {
+	*l = append(*l, d)
+}
+
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the list as an error, or nil if it contains no errors.
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l
+}
+
+func (l List) Error() string {
+	lines := make([]string, len(l))
+	for i, d := range l {
+		lines[i] = d.Error()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,3 @@
+module github.com/jinpei0908/rewriting-JackCompiler
+
+go 1.21
@@ -0,0 +1,194 @@
+// Package hackcpu simulates the Hack CPU, counting the cycles a program takes
+// to compare the code generation strategies.
+//
+// Translated programs start by calling Sys.init, so every class a program
+// calls must be given in VM form. The Jack OS only exists natively, for the
+// VM emulator, which leaves the programs calling it, such as the Pong and
+// Square testcases, out of reach of the CPU: cycle costs are measured on
+// programs that do without the OS.
+package hackcpu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/assembler"
+)
+
+// Addresses of the memory maps of the Hack platform.
+const (
+	Screen     = 16384
+	Keyboard   = 24576
+	MemorySize = Keyboard + 1
+)
+
+// ErrCycleLimit is returned by Run when the program does not halt in time.
+var ErrCycleLimit = errors.New("cycle limit reached")
+
+// CPU executes Hack machine code, one instruction per cycle. The screen and
+// the keyboard are plain memory: the host reads the screen memory map and
+// writes the key code into RAM[Keyboard].
+type CPU struct {
+	ROM []uint16
+	RAM [MemorySize]int16
+	A   int16
+	D   int16
+	PC  uint16
+	// Cycles counts the instructions executed since the last Reset.
+	Cycles int
+
+	halted bool
+}
+
+func New(rom []uint16) *CPU {
+	return &CPU{ROM: rom}
+}
+
+// LoadFile returns a CPU running the program of the named .hack or .asm file.
+func LoadFile(path string) (*CPU, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(path, file)
+}
+
+// Load returns a CPU running the program read from r, which is machine code
+// if filename ends with .hack and assembly otherwise.
+func Load(filename string, r io.Reader) (*CPU, error) {
+	var rom []uint16
+	var err error
+	if filepath.Ext(filename) == ".hack" {
+		rom, err = assembler.ReadHack(filename, r)
+	} else {
+		rom, err = assembler.Assemble(filename, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return New(rom), nil
+}
+
+// Reset restarts the program, as the reset pin does. The RAM is kept.
+func (c *CPU) Reset() {
+	c.PC = 0
+	c.Cycles = 0
+	c.halted = false
+}
+
+// Halted reports whether the program has reached a jump to itself, the way
+// Hack programs end.
+func (c *CPU) Halted() bool {
+	return c.halted
+}
+
+// Run executes instructions until the program halts. If maxCycles is not
+// zero, it returns ErrCycleLimit once Cycles reaches it.
+func (c *CPU) Run(maxCycles int) error {
+	for !c.halted {
+		if maxCycles > 0 && c.Cycles >= maxCycles {
+			return ErrCycleLimit
+		}
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Step executes one instruction.
+func (c *CPU) Step() error {
+	if int(c.PC) >= len(c.ROM) {
+		return fmt.Errorf("PC %v is out of the program", c.PC)
+	}
+	pc := c.PC
+	instruction := c.ROM[pc]
+	c.Cycles++
+
+	if instruction&0x8000 == 0 {
+		c.A = int16(instruction)
+		c.PC++
+		return nil
+	}
+
+	address := c.A
+	y := c.A
+	if instruction&0x1000 != 0 {
+		if err := c.checkAddress(pc, address); err != nil {
+			return err
+		}
+		y = c.RAM[address]
+	}
+	out := alu(c.D, y, instruction>>6&0x3f)
+
+	if instruction&0x08 != 0 {
+		if err := c.checkAddress(pc, address); err != nil {
+			return err
+		}
+		c.RAM[address] = out
+	}
+	if instruction&0x20 != 0 {
+		c.A = out
+	}
+	if instruction&0x10 != 0 {
+		c.D = out
+	}
+
+	if !jumps(out, instruction&0x07) {
+		c.PC++
+		return nil
+	}
+	c.PC = uint16(address)
+	// "(END) @END 0;JMP" loops forever.
+	if instruction&0x07 == 0x07 && pc > 0 && c.PC == pc-1 && c.ROM[c.PC] == uint16(c.PC) {
+		c.halted = true
+	}
+	return nil
+}
+
+func (c *CPU) checkAddress(pc uint16, address int16) error {
+	if address < 0 || int(address) >= MemorySize {
+		return fmt.Errorf("instruction %v accesses address %v, which is out of memory", pc, address)
+	}
+	return nil
+}
+
+// alu computes the output of the Hack ALU for the control bits zx nx zy ny f
+// no of a C-instruction.
+func alu(x, y int16, control uint16) int16 {
+	if control&0x20 != 0 {
+		x = 0
+	}
+	if control&0x10 != 0 {
+		x = ^x
+	}
+	if control&0x08 != 0 {
+		y = 0
+	}
+	if control&0x04 != 0 {
+		y = ^y
+	}
+	out := x & y
+	if control&0x02 != 0 {
+		out = x + y
+	}
+	if control&0x01 != 0 {
+		out = ^out
+	}
+	return out
+}
+
+func jumps(out int16, jump uint16) bool {
+	return jump&0x04 != 0 && out < 0 ||
+		jump&0x02 != 0 && out == 0 ||
+		jump&0x01 != 0 && out > 0
+}
+
+// Pixel reports whether the pixel of the 512x256 screen at x, y is black.
+func (c *CPU) Pixel(x, y int) bool {
+	return c.RAM[Screen+y*32+x/16]&(1<<uint(x%16)) != 0
+}
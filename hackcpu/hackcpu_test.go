@@ -0,0 +1,278 @@
+package hackcpu
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compilationengine"
+	"github.com/jinpei0908/rewriting-JackCompiler/optimizer"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmtranslator"
+)
+
+func TestRunAssembly(t *testing.T) {
+	cpu, err := Load("Max.asm", strings.NewReader(`@R0
+D=M
+@R1
+D=D-M
+@FIRST
+D;JGT
+@R1
+D=M
+@OUTPUT
+0;JMP
+(FIRST)
+@R0
+D=M
+(OUTPUT)
+@R2
+M=D
+(END)
+@END
+0;JMP
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ x, y, max int16 }{{3, 5, 5}, {-2, -7, -2}, {4, 4, 4}} {
+		cpu.Reset()
+		cpu.RAM[0], cpu.RAM[1] = c.x, c.y
+		if err := cpu.Run(100); err != nil {
+			t.Fatal(err)
+		}
+		if cpu.RAM[2] != c.max {
+			t.Errorf("max(%v, %v) = %v", c.x, c.y, cpu.RAM[2])
+		}
+	}
+}
+
+func TestCycleLimit(t *testing.T) {
+	cpu, _ := Load("Loop.asm", strings.NewReader("(LOOP)\n@R0\nM=M+1\n@LOOP\n0;JMP\n"))
+	if err := cpu.Run(1000); err != ErrCycleLimit {
+		t.Errorf("expected the cycle limit, got %v", err)
+	}
+	if cpu.Cycles != 1000 || cpu.RAM[0] != 250 {
+		t.Errorf("ran %v cycles, counted %v loops", cpu.Cycles, cpu.RAM[0])
+	}
+}
+
+// TestRunCompiledProgram compiles a Jack program down to machine code and
+// checks that the CPU computes what the VM emulator does.
+func TestRunCompiledProgram(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "Main.jack")
+	err := os.WriteFile(source, []byte(`class Main {
+  function void main() {
+    var Array out;
+    let out = 8000;
+    let out[0] = Main.fib(12);
+    return;
+  }
+  function int fib(int n) {
+    if (n < 2) {
+      return n;
+    }
+    return Main.fib(n - 1) + Main.fib(n - 2);
+  }
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputFile, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inputFile.Close()
+	outputFile, err := os.Create(filepath.Join(dir, "Main.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugFile, err := os.Create(filepath.Join(dir, "Main.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer debugFile.Close()
+	if err := compilationengine.NewCompilationEngine(inputFile, outputFile, debugFile).CompileClass(); err != nil {
+		t.Fatal(err)
+	}
+	outputFile.Close()
+	mainVM, err := os.ReadFile(filepath.Join(dir, "Main.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sysVM := "function Sys.init 0\ncall Main.main 0\npop temp 0\nlabel HALT\ngoto HALT\n"
+
+	m := vmemu.New()
+	m.Load("Main.vm", bytes.NewReader(mainVM))
+	m.Load("Sys.vm", strings.NewReader(sysVM))
+	if _, err := m.Call("Main.main"); err != nil {
+		t.Fatal(err)
+	}
+
+	var asm bytes.Buffer
+	tr := vmtranslator.NewTranslator(&asm)
+	tr.WriteBootstrap()
+	tr.Translate("Main.vm", bytes.NewReader(mainVM))
+	tr.Translate("Sys.vm", strings.NewReader(sysVM))
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	cpu, err := Load("Main.asm", &asm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cpu.Run(10000000); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.RAM[8000] != 144 || cpu.RAM[8000] != m.RAM[8000] {
+		t.Errorf("CPU computed %v, VM emulator %v; expect 144", cpu.RAM[8000], m.RAM[8000])
+	}
+	t.Logf("fib(12) took %v cycles for %v VM instructions", cpu.Cycles, m.Steps)
+}
+
+// TestComparisonsAgreeWithVMEmulator checks eq, gt and lt on operands whose
+// difference overflows, which the CPU must compare like the VM emulator.
+func TestComparisonsAgreeWithVMEmulator(t *testing.T) {
+	push := func(v int) string {
+		switch {
+		case v >= 0:
+			return fmt.Sprintf("push constant %v\n", v)
+		case v == -32768:
+			return "push constant 32767\nneg\npush constant 1\nsub\n"
+		}
+		return fmt.Sprintf("push constant %v\nneg\n", -v)
+	}
+	pairs := [][2]int{{20000, -20000}, {-20000, 20000}, {32767, -32768}, {-32768, 32767}, {-5, -3}, {7, 7}, {0, -1}}
+	var mainVM strings.Builder
+	mainVM.WriteString("function Main.main 0\npush constant 8000\npop pointer 1\n")
+	n := 0
+	for _, pair := range pairs {
+		for _, op := range []string{"eq", "gt", "lt"} {
+			mainVM.WriteString(push(pair[0]) + push(pair[1]) + op + "\n")
+			fmt.Fprintf(&mainVM, "pop that %v\n", n)
+			n++
+		}
+	}
+	mainVM.WriteString("push constant 0\nreturn\n")
+	sysVM := "function Sys.init 0\ncall Main.main 0\npop temp 0\nlabel HALT\ngoto HALT\n"
+
+	m := vmemu.New()
+	m.Load("Main.vm", strings.NewReader(mainVM.String()))
+	m.Load("Sys.vm", strings.NewReader(sysVM))
+	if _, err := m.Call("Main.main"); err != nil {
+		t.Fatal(err)
+	}
+
+	var asm bytes.Buffer
+	tr := vmtranslator.NewTranslator(&asm)
+	tr.WriteBootstrap()
+	tr.Translate("Main.vm", strings.NewReader(mainVM.String()))
+	tr.Translate("Sys.vm", strings.NewReader(sysVM))
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	cpu, err := Load("Main.asm", &asm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cpu.Run(100000); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		pair, op := pairs[i/3], []string{"eq", "gt", "lt"}[i%3]
+		if cpu.RAM[8000+i] != m.RAM[8000+i] {
+			t.Errorf("%v %v %v: CPU computed %v, VM emulator %v", pair[0], op, pair[1], cpu.RAM[8000+i], m.RAM[8000+i])
+		}
+	}
+	if m.RAM[8001] != -1 || m.RAM[8004] != 0 {
+		t.Errorf("VM emulator: 20000 gt -20000 = %v, -20000 gt 20000 = %v", m.RAM[8001], m.RAM[8004])
+	}
+}
+
+// runOnCPU compiles a class Main calling no OS, optimized or not, and runs it
+// on the CPU.
+func runOnCPU(t *testing.T, source string, optimize bool) *CPU {
+	dir := t.TempDir()
+	inputFile, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inputFile.Close()
+	outputFile, err := os.Create(filepath.Join(dir, "Main.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugFile, err := os.Create(filepath.Join(dir, "Main.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer debugFile.Close()
+	ce := compilationengine.NewCompilationEngine(inputFile, outputFile, debugFile)
+	if optimize {
+		ce.SetOptimizer(optimizer.Optimize)
+	}
+	if err := ce.CompileClass(); err != nil {
+		t.Fatal(err)
+	}
+	outputFile.Close()
+	mainVM, err := os.ReadFile(filepath.Join(dir, "Main.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var asm bytes.Buffer
+	tr := vmtranslator.NewTranslator(&asm)
+	tr.WriteBootstrap()
+	tr.Translate("Main.vm", bytes.NewReader(mainVM))
+	tr.Translate("Sys.vm", strings.NewReader("function Sys.init 0\ncall Main.main 0\npop temp 0\nlabel HALT\ngoto HALT\n"))
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	cpu, err := Load("Main.asm", &asm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cpu.Run(10000000); err != nil {
+		t.Fatal(err)
+	}
+	return cpu
+}
+
+// TestOptimizerSavesCycles compares the cycle costs of a program compiled
+// with and without the peephole optimizer.
+func TestOptimizerSavesCycles(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "Main.jack")
+	err := os.WriteFile(source, []byte(`class Main {
+  function void main() {
+    var Array out;
+    var int i, sum;
+    let out = 8000;
+    let i = 0;
+    while (i < 100) {
+      let sum = sum + i;
+      let out[i + 1] = sum;
+      let i = i + 1;
+    }
+    let out[0] = sum;
+    return;
+  }
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := runOnCPU(t, source, false)
+	optimized := runOnCPU(t, source, true)
+	if plain.RAM[8000] != 4950 || optimized.RAM[8000] != 4950 {
+		t.Fatalf("computed %v and %v optimized; expect 4950", plain.RAM[8000], optimized.RAM[8000])
+	}
+	if optimized.Cycles >= plain.Cycles {
+		t.Errorf("optimized code took %v cycles, not fewer than %v", optimized.Cycles, plain.Cycles)
+	}
+	t.Logf("%v cycles, %v optimized", plain.Cycles, optimized.Cycles)
+}
@@ -0,0 +1,27 @@
+// Package jacktest holds the helpers shared by the tests of the compiler
+// packages.
+package jacktest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/jacktokenizer"
+	"github.com/jinpei0908/rewriting-JackCompiler/parser"
+)
+
+// Parse returns the syntax tree of the class src, failing the test if it
+// cannot be tokenized or parsed.
+func Parse(t testing.TB, src string) *ast.Class {
+	t.Helper()
+	tk, err := jacktokenizer.NewTokenizer(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	class, err := parser.NewParser(tk, "Main.jack").ParseClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return class
+}
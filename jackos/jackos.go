@@ -0,0 +1,158 @@
+package jackos
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// OS implements the classes of the Jack OS in Go for the VM emulator. Text
+// printed by Output is captured in Output instead of being drawn, Screen draws
+// into the screen memory map of the machine, and Keyboard reads from Input.
+type OS struct {
+	// Output receives every character printed by the program.
+	Output bytes.Buffer
+	// Input holds the key codes read by Keyboard.readChar, readLine and
+	// readInt, in order. Use Type to add text.
+	Input []int16
+
+	black bool
+	heap  heap
+}
+
+func New() *OS {
+	return &OS{black: true, heap: newHeap()}
+}
+
+// Install registers the OS functions in m. Functions loaded as VM code, such
+// as an OS class written in Jack, take precedence over them.
+func (o *OS) Install(m *vmemu.Machine) {
+	builtins := []struct {
+		name  string
+		nArgs int
+		f     vmemu.Builtin
+	}{
+		{"Sys.init", 0, o.sysInit},
+		{"Sys.halt", 0, o.sysHalt},
+		{"Sys.error", 1, o.sysError},
+		{"Sys.wait", 1, o.sysWait},
+
+		{"Memory.init", 0, o.init},
+		{"Memory.peek", 1, o.memoryPeek},
+		{"Memory.poke", 2, o.memoryPoke},
+		{"Memory.alloc", 1, o.memoryAlloc},
+		{"Memory.deAlloc", 1, o.memoryDeAlloc},
+		{"Array.new", 1, o.arrayNew},
+		{"Array.dispose", 1, o.memoryDeAlloc},
+
+		{"Math.init", 0, o.init},
+		{"Math.abs", 1, o.mathAbs},
+		{"Math.multiply", 2, o.mathMultiply},
+		{"Math.divide", 2, o.mathDivide},
+		{"Math.min", 2, o.mathMin},
+		{"Math.max", 2, o.mathMax},
+		{"Math.sqrt", 1, o.mathSqrt},
+
+		{"String.new", 1, o.stringNew},
+		{"String.dispose", 1, o.memoryDeAlloc},
+		{"String.length", 1, o.stringLength},
+		{"String.charAt", 2, o.stringCharAt},
+		{"String.setCharAt", 3, o.stringSetCharAt},
+		{"String.appendChar", 2, o.stringAppendChar},
+		{"String.eraseLastChar", 1, o.stringEraseLastChar},
+		{"String.intValue", 1, o.stringIntValue},
+		{"String.setInt", 2, o.stringSetInt},
+		{"String.backSpace", 0, constant(backSpace)},
+		{"String.doubleQuote", 0, constant('"')},
+		{"String.newLine", 0, constant(newLine)},
+
+		{"Output.init", 0, o.init},
+		{"Output.moveCursor", 2, o.outputMoveCursor},
+		{"Output.printChar", 1, o.outputPrintChar},
+		{"Output.printString", 1, o.outputPrintString},
+		{"Output.printInt", 1, o.outputPrintInt},
+		{"Output.println", 0, o.outputPrintln},
+		{"Output.backSpace", 0, o.outputBackSpace},
+
+		{"Screen.init", 0, o.init},
+		{"Screen.clearScreen", 0, o.screenClearScreen},
+		{"Screen.setColor", 1, o.screenSetColor},
+		{"Screen.drawPixel", 2, o.screenDrawPixel},
+		{"Screen.drawLine", 4, o.screenDrawLine},
+		{"Screen.drawRectangle", 4, o.screenDrawRectangle},
+		{"Screen.drawCircle", 3, o.screenDrawCircle},
+
+		{"Keyboard.init", 0, o.init},
+		{"Keyboard.keyPressed", 0, o.keyboardKeyPressed},
+		{"Keyboard.readChar", 0, o.keyboardReadChar},
+		{"Keyboard.readLine", 1, o.keyboardReadLine},
+		{"Keyboard.readInt", 1, o.keyboardReadInt},
+	}
+	for _, builtin := range builtins {
+		m.Register(builtin.name, checkArgs(builtin.name, builtin.nArgs, builtin.f))
+	}
+}
+
+// Error is the error raised by Sys.error, or by an OS function that detects
+// an illegal argument. Code is the error code of the Jack OS.
+type Error struct {
+	Code    int16
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Sys.error %v", e.Code)
+	}
+	return fmt.Sprintf("Sys.error %v: %v", e.Code, e.Message)
+}
+
+func sysError(code int16, message string) error {
+	return &Error{Code: code, Message: message}
+}
+
+// checkArgs guards f against calls with the wrong number of arguments, which
+// hand-written VM code can make.
+func checkArgs(name string, nArgs int, f vmemu.Builtin) vmemu.Builtin {
+	return func(m *vmemu.Machine, args []int16) (int16, error) {
+		if len(args) != nArgs {
+			return 0, fmt.Errorf("%v expects %v arguments, found %v", name, nArgs, len(args))
+		}
+		return f(m, args)
+	}
+}
+
+func constant(value int16) vmemu.Builtin {
+	return func(m *vmemu.Machine, args []int16) (int16, error) {
+		return value, nil
+	}
+}
+
+func (o *OS) init(m *vmemu.Machine, args []int16) (int16, error) {
+	return 0, nil
+}
+
+// sysInit runs Main.main and halts, as the bootstrap code expects.
+func (o *OS) sysInit(m *vmemu.Machine, args []int16) (int16, error) {
+	if _, err := m.Call("Main.main"); err != nil {
+		return 0, err
+	}
+	return 0, vmemu.ErrHalt
+}
+
+func (o *OS) sysHalt(m *vmemu.Machine, args []int16) (int16, error) {
+	return 0, vmemu.ErrHalt
+}
+
+func (o *OS) sysError(m *vmemu.Machine, args []int16) (int16, error) {
+	return 0, sysError(args[0], "")
+}
+
+// sysWait returns at once: headless programs have nobody to wait for.
+func (o *OS) sysWait(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 {
+		return 0, sysError(1, "duration must be positive")
+	}
+	return 0, nil
+}
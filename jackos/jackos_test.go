@@ -0,0 +1,137 @@
+package jackos
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compilationengine"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// compileProgram compiles every class of a testcases directory and loads it
+// into a machine running the OS.
+func compileProgram(t *testing.T, name string) (*vmemu.Machine, *OS) {
+	dir := t.TempDir()
+	sources, _ := filepath.Glob(filepath.Join("../testcases", name, "*.jack"))
+	if len(sources) == 0 {
+		t.Fatalf("no jack files in testcases/%v", name)
+	}
+	for _, source := range sources {
+		base := strings.TrimSuffix(filepath.Base(source), ".jack")
+		inputFile, err := os.Open(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputFile, err := os.Create(filepath.Join(dir, base+".vm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		debugFile, err := os.Create(filepath.Join(dir, base+".xml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = compilationengine.NewCompilationEngine(inputFile, outputFile, debugFile).CompileClass()
+		inputFile.Close()
+		outputFile.Close()
+		debugFile.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := vmemu.New()
+	if err := m.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	m.MaxSteps = 1000000
+	jackOS := New()
+	jackOS.Install(m)
+	return m, jackOS
+}
+
+func TestSeven(t *testing.T) {
+	m, jackOS := compileProgram(t, "Seven")
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if output := jackOS.Output.String(); output != "7" {
+		t.Errorf("output %q; expect %q", output, "7")
+	}
+}
+
+func TestComplexArrays(t *testing.T) {
+	m, jackOS := compileProgram(t, "ComplexArrays")
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	result := regexp.MustCompile(`expected result: (-?\d+); actual result: (-?\d+)`)
+	lines := strings.Split(strings.TrimSpace(jackOS.Output.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("output:\n%v", jackOS.Output.String())
+	}
+	for _, line := range lines {
+		if match := result.FindStringSubmatch(line); match == nil || match[1] != match[2] {
+			t.Errorf("failed: %v", line)
+		}
+	}
+}
+
+func TestAverage(t *testing.T) {
+	m, jackOS := compileProgram(t, "Average")
+	jackOS.Type("3\n1\n2\n6\n")
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expect := "How many numbers? 3\nEnter a number: 1\nEnter a number: 2\nEnter a number: 6\nThe average is 3"
+	if output := jackOS.Output.String(); output != expect {
+		t.Errorf("\nactual: %q\nexpect: %q\n", output, expect)
+	}
+}
+
+func TestConvertToBin(t *testing.T) {
+	m, _ := compileProgram(t, "ConvertToBin")
+	m.RAM[8000] = 0x2a
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		if bit := m.RAM[8001+i]; bit != (0x2a>>uint(i))&1 {
+			t.Errorf("RAM[%v] = %v", 8001+i, bit)
+		}
+	}
+}
+
+func TestSquareDrawsOnScreen(t *testing.T) {
+	m, _ := compileProgram(t, "Square")
+	m.MaxSteps = 100000
+	// The game waits for keys forever, so it is stopped by MaxSteps.
+	if err := m.Run(); err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Fatalf("expected the game to be stopped, got %v", err)
+	}
+	img := Bitmap(m)
+	if img.GrayAt(0, 0).Y != 0 || img.GrayAt(29, 29).Y != 0 || img.GrayAt(31, 31).Y != 255 {
+		t.Errorf("the square is not drawn at the top left corner")
+	}
+}
+
+func TestHeap(t *testing.T) {
+	h := newHeap()
+	a := h.alloc(10)
+	b := h.alloc(20)
+	c := h.alloc(30)
+	if a != vmemu.HeapBase || b != a+10 || c != b+20 {
+		t.Fatalf("alloc returned %v, %v, %v", a, b, c)
+	}
+	h.deAlloc(a)
+	h.deAlloc(c)
+	h.deAlloc(b)
+	if len(h.free) != 1 || h.free[0].size != vmemu.Screen-vmemu.HeapBase {
+		t.Errorf("freed blocks are not merged: %v", h.free)
+	}
+	if h.deAlloc(b) {
+		t.Errorf("freed a block twice")
+	}
+}
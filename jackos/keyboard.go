@@ -0,0 +1,96 @@
+package jackos
+
+import (
+	"errors"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// ErrNoInput is returned when the program reads more keys than Input holds.
+// Without it a headless program would wait for a key forever.
+var ErrNoInput = errors.New("no more keyboard input")
+
+// Type adds the characters of s to Input. '\n' is typed as the newline key.
+func (o *OS) Type(s string) {
+	for _, c := range s {
+		if c == '\n' {
+			c = newLine
+		}
+		o.Input = append(o.Input, int16(c))
+	}
+}
+
+// keyboardKeyPressed reads the keyboard memory map, which the host may set to
+// simulate a key held down.
+func (o *OS) keyboardKeyPressed(m *vmemu.Machine, args []int16) (int16, error) {
+	return m.RAM[vmemu.Keyboard], nil
+}
+
+func (o *OS) keyboardReadChar(m *vmemu.Machine, args []int16) (int16, error) {
+	return o.readChar()
+}
+
+// readChar takes the next key of Input and echoes it, as the OS does.
+func (o *OS) readChar() (int16, error) {
+	if len(o.Input) == 0 {
+		return 0, ErrNoInput
+	}
+	c := o.Input[0]
+	o.Input = o.Input[1:]
+	o.printChar(c)
+	return c, nil
+}
+
+func (o *OS) keyboardReadLine(m *vmemu.Machine, args []int16) (int16, error) {
+	line, err := o.readLine(m, args[0])
+	if err != nil {
+		return 0, err
+	}
+	s, err := o.stringNew(m, []int16{int16(len(line))})
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range line {
+		if _, err := o.stringAppendChar(m, []int16{s, c}); err != nil {
+			return 0, err
+		}
+	}
+	return s, nil
+}
+
+func (o *OS) keyboardReadInt(m *vmemu.Machine, args []int16) (int16, error) {
+	line, err := o.readLine(m, args[0])
+	if err != nil {
+		return 0, err
+	}
+	chars := make([]byte, len(line))
+	for i, c := range line {
+		chars[i] = byte(c)
+	}
+	return int16(parseInt(string(chars))), nil
+}
+
+// readLine prints message and reads keys up to a newline, handling back
+// spaces.
+func (o *OS) readLine(m *vmemu.Machine, message int16) ([]int16, error) {
+	if _, err := o.outputPrintString(m, []int16{message}); err != nil {
+		return nil, err
+	}
+	line := []int16{}
+	for {
+		c, err := o.readChar()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case newLine:
+			return line, nil
+		case backSpace:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		default:
+			line = append(line, c)
+		}
+	}
+}
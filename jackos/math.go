@@ -0,0 +1,53 @@
+package jackos
+
+import (
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// Arithmetic wraps around on 16 bits, as it does on the Hack platform.
+
+func (o *OS) mathAbs(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 {
+		return -args[0], nil
+	}
+	return args[0], nil
+}
+
+func (o *OS) mathMultiply(m *vmemu.Machine, args []int16) (int16, error) {
+	return args[0] * args[1], nil
+}
+
+// mathDivide truncates toward zero.
+func (o *OS) mathDivide(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[1] == 0 {
+		return 0, sysError(3, "division by zero")
+	}
+	return args[0] / args[1], nil
+}
+
+func (o *OS) mathMin(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < args[1] {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+func (o *OS) mathMax(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] > args[1] {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+// mathSqrt returns the integer part of the square root.
+func (o *OS) mathSqrt(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 {
+		return 0, sysError(4, "cannot compute square root of a negative number")
+	}
+	x := int(args[0])
+	y := 0
+	for (y+1)*(y+1) <= x {
+		y++
+	}
+	return int16(y), nil
+}
@@ -0,0 +1,113 @@
+package jackos
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// heap allocates blocks of RAM between vmemu.HeapBase and the screen. The
+// bookkeeping is kept in Go, so programs that overwrite the heap can only
+// corrupt their own data.
+type heap struct {
+	free      []block // sorted by address, never adjacent
+	allocated map[int]int
+}
+
+type block struct {
+	address, size int
+}
+
+func newHeap() heap {
+	return heap{
+		free:      []block{{vmemu.HeapBase, vmemu.Screen - vmemu.HeapBase}},
+		allocated: map[int]int{}}
+}
+
+// alloc returns the address of a free block of size words, using the first
+// block that fits, or -1 if there is none.
+func (h *heap) alloc(size int) int {
+	for i, b := range h.free {
+		if b.size < size {
+			continue
+		}
+		if b.size == size {
+			h.free = append(h.free[:i], h.free[i+1:]...)
+		} else {
+			h.free[i] = block{b.address + size, b.size - size}
+		}
+		h.allocated[b.address] = size
+		return b.address
+	}
+	return -1
+}
+
+// deAlloc frees the block at address and reports whether it was allocated.
+func (h *heap) deAlloc(address int) bool {
+	size, ok := h.allocated[address]
+	if !ok {
+		return false
+	}
+	delete(h.allocated, address)
+
+	i := sort.Search(len(h.free), func(i int) bool { return h.free[i].address > address })
+	h.free = append(h.free, block{})
+	copy(h.free[i+1:], h.free[i:])
+	h.free[i] = block{address, size}
+	if i+1 < len(h.free) && address+size == h.free[i+1].address {
+		h.free[i].size += h.free[i+1].size
+		h.free = append(h.free[:i+1], h.free[i+2:]...)
+	}
+	if i > 0 && h.free[i-1].address+h.free[i-1].size == address {
+		h.free[i-1].size += h.free[i].size
+		h.free = append(h.free[:i], h.free[i+1:]...)
+	}
+	return true
+}
+
+func (o *OS) memoryPeek(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 {
+		return 0, errors.New("address is out of memory")
+	}
+	return m.RAM[args[0]], nil
+}
+
+func (o *OS) memoryPoke(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 {
+		return 0, errors.New("address is out of memory")
+	}
+	m.RAM[args[0]] = args[1]
+	return 0, nil
+}
+
+func (o *OS) memoryAlloc(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] <= 0 {
+		return 0, sysError(5, "allocated memory size must be positive")
+	}
+	return o.alloc(int(args[0]))
+}
+
+func (o *OS) alloc(size int) (int16, error) {
+	address := o.heap.alloc(size)
+	if address < 0 {
+		return 0, sysError(6, "heap overflow")
+	}
+	return int16(address), nil
+}
+
+// memoryDeAlloc also implements Array.dispose and String.dispose, whose
+// argument is the object itself.
+func (o *OS) memoryDeAlloc(m *vmemu.Machine, args []int16) (int16, error) {
+	if !o.heap.deAlloc(int(args[0])) {
+		return 0, errors.New("object was not allocated")
+	}
+	return 0, nil
+}
+
+func (o *OS) arrayNew(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] <= 0 {
+		return 0, sysError(2, "array size must be positive")
+	}
+	return o.alloc(int(args[0]))
+}
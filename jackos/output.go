@@ -0,0 +1,68 @@
+package jackos
+
+import (
+	"strconv"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// The Jack OS prints text on a grid of 23 rows of 64 characters.
+const (
+	rows    = 23
+	columns = 64
+)
+
+// outputMoveCursor only checks its arguments: the captured output is a stream
+// of text and has no cursor.
+func (o *OS) outputMoveCursor(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 || args[0] >= rows || args[1] < 0 || args[1] >= columns {
+		return 0, sysError(20, "illegal cursor location")
+	}
+	return 0, nil
+}
+
+func (o *OS) outputPrintChar(m *vmemu.Machine, args []int16) (int16, error) {
+	o.printChar(args[0])
+	return 0, nil
+}
+
+func (o *OS) outputPrintString(m *vmemu.Machine, args []int16) (int16, error) {
+	s := args[0]
+	for i := int16(0); i < o.field(m, s, lengthField); i++ {
+		o.printChar(o.field(m, s, charsField+i))
+	}
+	return 0, nil
+}
+
+func (o *OS) outputPrintInt(m *vmemu.Machine, args []int16) (int16, error) {
+	o.Output.WriteString(strconv.Itoa(int(args[0])))
+	return 0, nil
+}
+
+func (o *OS) outputPrintln(m *vmemu.Machine, args []int16) (int16, error) {
+	o.Output.WriteByte('\n')
+	return 0, nil
+}
+
+func (o *OS) outputBackSpace(m *vmemu.Machine, args []int16) (int16, error) {
+	o.printChar(backSpace)
+	return 0, nil
+}
+
+// printChar writes c to Output. A back space erases the last character of the
+// current line, as it does on the screen, and characters the OS font cannot
+// draw are written as '?'.
+func (o *OS) printChar(c int16) {
+	switch {
+	case c == newLine:
+		o.Output.WriteByte('\n')
+	case c == backSpace:
+		if n := o.Output.Len(); n > 0 && o.Output.Bytes()[n-1] != '\n' {
+			o.Output.Truncate(n - 1)
+		}
+	case 32 <= c && c <= 126:
+		o.Output.WriteByte(byte(c))
+	default:
+		o.Output.WriteByte('?')
+	}
+}
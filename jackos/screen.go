@@ -0,0 +1,142 @@
+package jackos
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// The screen is 256 rows of 512 pixels, mapped in memory from vmemu.Screen
+// with 16 pixels per word, the leftmost in the least significant bit.
+const (
+	Width  = 512
+	Height = 256
+)
+
+// Bitmap renders the screen memory map of m as a 512x256 image, black pixels
+// being set bits.
+func Bitmap(m *vmemu.Machine) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, Width, Height))
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			c := color.Gray{Y: 255}
+			if pixel(m, x, y) {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	return img
+}
+
+func pixel(m *vmemu.Machine, x, y int) bool {
+	return m.RAM[vmemu.Screen+y*Width/16+x/16]&(1<<uint(x%16)) != 0
+}
+
+func onScreen(x, y int16) bool {
+	return 0 <= x && x < Width && 0 <= y && y < Height
+}
+
+func (o *OS) setPixel(m *vmemu.Machine, x, y int) {
+	address := vmemu.Screen + y*Width/16 + x/16
+	bit := int16(1) << uint(x%16)
+	if o.black {
+		m.RAM[address] |= bit
+	} else {
+		m.RAM[address] &^= bit
+	}
+}
+
+func (o *OS) screenClearScreen(m *vmemu.Machine, args []int16) (int16, error) {
+	for address := vmemu.Screen; address < vmemu.Keyboard; address++ {
+		m.RAM[address] = 0
+	}
+	return 0, nil
+}
+
+func (o *OS) screenSetColor(m *vmemu.Machine, args []int16) (int16, error) {
+	o.black = args[0] != 0
+	return 0, nil
+}
+
+func (o *OS) screenDrawPixel(m *vmemu.Machine, args []int16) (int16, error) {
+	if !onScreen(args[0], args[1]) {
+		return 0, sysError(7, "illegal pixel coordinates")
+	}
+	o.setPixel(m, int(args[0]), int(args[1]))
+	return 0, nil
+}
+
+func (o *OS) screenDrawLine(m *vmemu.Machine, args []int16) (int16, error) {
+	if !onScreen(args[0], args[1]) || !onScreen(args[2], args[3]) {
+		return 0, sysError(8, "illegal line coordinates")
+	}
+	x1, y1, x2, y2 := int(args[0]), int(args[1]), int(args[2]), int(args[3])
+	dx, dy := abs(x2-x1), -abs(y2-y1)
+	sx, sy := sign(x2-x1), sign(y2-y1)
+	for e := dx + dy; ; {
+		o.setPixel(m, x1, y1)
+		if x1 == x2 && y1 == y2 {
+			return 0, nil
+		}
+		if 2*e >= dy {
+			e += dy
+			x1 += sx
+		}
+		if 2*e <= dx {
+			e += dx
+			y1 += sy
+		}
+	}
+}
+
+func (o *OS) screenDrawRectangle(m *vmemu.Machine, args []int16) (int16, error) {
+	x1, y1, x2, y2 := args[0], args[1], args[2], args[3]
+	if !onScreen(x1, y1) || !onScreen(x2, y2) || x1 > x2 || y1 > y2 {
+		return 0, sysError(9, "illegal rectangle coordinates")
+	}
+	for y := int(y1); y <= int(y2); y++ {
+		for x := int(x1); x <= int(x2); x++ {
+			o.setPixel(m, x, y)
+		}
+	}
+	return 0, nil
+}
+
+// screenDrawCircle draws a filled circle, clipped to the screen.
+func (o *OS) screenDrawCircle(m *vmemu.Machine, args []int16) (int16, error) {
+	cx, cy, r := int(args[0]), int(args[1]), int(args[2])
+	if !onScreen(args[0], args[1]) {
+		return 0, sysError(12, "illegal center coordinates")
+	}
+	if r < 0 || r > 181 {
+		return 0, sysError(13, "illegal radius")
+	}
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			x, y := cx+dx, cy+dy
+			if dx*dx+dy*dy <= r*r && onScreen(int16(x), int16(y)) {
+				o.setPixel(m, x, y)
+			}
+		}
+	}
+	return 0, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+package jackos
+
+import (
+	"strconv"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// Character codes of the Jack character set that are not ASCII.
+const (
+	newLine   = 128
+	backSpace = 129
+)
+
+// A String is a heap block holding its maximum length, its length and then
+// its characters.
+const (
+	maxLengthField = 0
+	lengthField    = 1
+	charsField     = 2
+)
+
+func (o *OS) stringNew(m *vmemu.Machine, args []int16) (int16, error) {
+	if args[0] < 0 {
+		return 0, sysError(14, "maximum length must be non-negative")
+	}
+	s, err := o.alloc(charsField + int(args[0]))
+	if err != nil {
+		return 0, err
+	}
+	m.RAM[s+maxLengthField] = args[0]
+	m.RAM[s+lengthField] = 0
+	return s, nil
+}
+
+func (o *OS) stringLength(m *vmemu.Machine, args []int16) (int16, error) {
+	return o.field(m, args[0], lengthField), nil
+}
+
+func (o *OS) stringCharAt(m *vmemu.Machine, args []int16) (int16, error) {
+	s, i := args[0], args[1]
+	if i < 0 || i >= o.field(m, s, lengthField) {
+		return 0, sysError(15, "string index out of bounds")
+	}
+	return o.field(m, s, charsField+i), nil
+}
+
+func (o *OS) stringSetCharAt(m *vmemu.Machine, args []int16) (int16, error) {
+	s, i := args[0], args[1]
+	if i < 0 || i >= o.field(m, s, lengthField) {
+		return 0, sysError(16, "string index out of bounds")
+	}
+	o.setField(m, s, charsField+i, args[2])
+	return 0, nil
+}
+
+func (o *OS) stringAppendChar(m *vmemu.Machine, args []int16) (int16, error) {
+	s := args[0]
+	length := o.field(m, s, lengthField)
+	if length >= o.field(m, s, maxLengthField) {
+		return 0, sysError(17, "string is full")
+	}
+	o.setField(m, s, charsField+length, args[1])
+	o.setField(m, s, lengthField, length+1)
+	return s, nil
+}
+
+func (o *OS) stringEraseLastChar(m *vmemu.Machine, args []int16) (int16, error) {
+	s := args[0]
+	length := o.field(m, s, lengthField)
+	if length == 0 {
+		return 0, sysError(18, "string is empty")
+	}
+	o.setField(m, s, lengthField, length-1)
+	return 0, nil
+}
+
+// stringIntValue converts the leading digits of the string, with an optional
+// minus sign, to an int.
+func (o *OS) stringIntValue(m *vmemu.Machine, args []int16) (int16, error) {
+	return int16(parseInt(o.goString(m, args[0]))), nil
+}
+
+func (o *OS) stringSetInt(m *vmemu.Machine, args []int16) (int16, error) {
+	s := args[0]
+	digits := strconv.Itoa(int(args[1]))
+	if len(digits) > int(o.field(m, s, maxLengthField)) {
+		return 0, sysError(19, "insufficient string capacity")
+	}
+	for i, c := range digits {
+		o.setField(m, s, charsField+int16(i), int16(c))
+	}
+	o.setField(m, s, lengthField, int16(len(digits)))
+	return 0, nil
+}
+
+// goString returns the characters of the String s.
+func (o *OS) goString(m *vmemu.Machine, s int16) string {
+	length := o.field(m, s, lengthField)
+	chars := make([]rune, 0, length)
+	for i := int16(0); i < length; i++ {
+		chars = append(chars, rune(o.field(m, s, charsField+i)))
+	}
+	return string(chars)
+}
+
+func parseInt(s string) int {
+	value, i, sign := 0, 0, 1
+	if len(s) > 0 && s[0] == '-' {
+		sign = -1
+		i++
+	}
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		value = value*10 + int(s[i]-'0')
+	}
+	return sign * value
+}
+
+// field reads a word of the object at address, as a method would through the
+// this segment. Addresses out of memory read as 0.
+func (o *OS) field(m *vmemu.Machine, object, index int16) int16 {
+	address := int(object) + int(index)
+	if address < 0 || address >= vmemu.MemorySize {
+		return 0
+	}
+	return m.RAM[address]
+}
+
+func (o *OS) setField(m *vmemu.Machine, object, index, value int16) {
+	address := int(object) + int(index)
+	if address >= 0 && address < vmemu.MemorySize {
+		m.RAM[address] = value
+	}
+}
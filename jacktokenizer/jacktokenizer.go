@@ -1,13 +1,8 @@
 package jacktokenizer
 
 import (
-	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
-	"regexp"
+	"io"
 	"strconv"
-	"strings"
 )
 
 type TokenTypes int
@@ -24,13 +19,10 @@ const (
 
 type Tokenizer struct {
 	index        int
-	tokens       []string
-	mapOfString  map[string]string
-	currentToken string
+	tokens       []Token
+	currentToken Token
 }
 
-var patternOfInteger = regexp.MustCompile("[0-9]+")
-var patternOfIdentifier = regexp.MustCompile("[A-z_].*")
 var symbols = []string{
 	"{", "}", "(", ")", "[", "]", ".", ",",
 	";", "+", "-", "*", "/", "&",
@@ -43,49 +35,34 @@ var keywords = []string{
 	"let", "do", "if", "else",
 	"while", "return"}
 
-func NewTokenizer(file *os.File) *Tokenizer {
-	b, err := ioutil.ReadAll(file)
+// NamedReader gives a name to a reader that has none, such as the source of
+// a class held in memory, so that the name labels the diagnostics as the
+// name of a file does.
+type NamedReader struct {
+	io.Reader
+	Filename string
+}
+
+func (r NamedReader) Name() string {
+	return r.Filename
+}
+
+// NewTokenizer reads all the tokens of r. If r has a Name method, as files
+// do, the name labels the diagnostics.
+func NewTokenizer(r io.Reader) (*Tokenizer, error) {
+	scanner := NewScanner(r)
+	if named, ok := r.(interface{ Name() string }); ok {
+		scanner.Filename = named.Name()
+	}
+	tokens, err := scanner.ScanAll()
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
 
-	b, newMap := replaceStringConstant(b)
-	b = removeOneLineComment(b)
-	b = removeNewLineAndTab(b)
-	b = removeMultiLineComment(b)
-	s := string(b)
-	symbols := strings.NewReplacer(
-		"{", " { ",
-		"}", " } ",
-		"(", " ( ",
-		")", " ) ",
-		"[", " [ ",
-		"]", " ] ",
-		".", " . ",
-		",", " , ",
-		";", " ; ",
-		"+", " + ",
-		"-", " - ",
-		"*", " * ",
-		"/", " / ",
-		"&", " & ",
-		"|", " | ",
-		"<", " < ",
-		">", " > ",
-		"=", " = ",
-		"~", " ~ ",
-	)
-
-	s = symbols.Replace(s)
-	s = replaceMultiSpaceToMonoSpace(s)
-
-	tokens := strings.Split(s, " ")
-
 	return &Tokenizer{
 		index:        0,
 		tokens:       tokens,
-		mapOfString:  newMap,
-		currentToken: ""}
+		currentToken: Token{Kind: None}}, nil
 }
 
 func (tk *Tokenizer) HasMoreTokens() bool {
@@ -93,123 +70,64 @@ func (tk *Tokenizer) HasMoreTokens() bool {
 }
 
 func (tk *Tokenizer) Advance() {
-	if tk.tokens[tk.index] == "" {
-		tk.index++
-	}
 	tk.currentToken = tk.tokens[tk.index]
 	tk.index++
 }
 
 func (tk *Tokenizer) TokenType() TokenTypes {
-	if isKeyword(tk.currentToken) {
-		return Keyword
-	}
-	if isSymbol(tk.currentToken) {
-		return Symbol
-	}
-	if strings.HasPrefix(tk.currentToken, "StringConstant_") {
-		return StringConst
-	}
-	if patternOfInteger.MatchString(tk.currentToken) {
-		return IntConst
-	}
-	if patternOfIdentifier.MatchString(tk.currentToken) {
-		return Identifier
-	}
-	return None
+	return tk.currentToken.Kind
 }
 
+// Keyword returns the current keyword, or "" if the current token is not a
+// keyword.
 func (tk *Tokenizer) Keyword() string {
-	for _, k := range keywords {
-		if tk.currentToken == k {
-			return tk.currentToken
-		}
+	if tk.currentToken.Kind == Keyword {
+		return tk.currentToken.Text
 	}
-	log.Fatalln("This token is not keyword", tk.currentToken)
 	return ""
 }
 
+// Symbol returns the current symbol as written in the source. Writers of XML
+// escape it with xmlwriter.Escape.
 func (tk *Tokenizer) Symbol() string {
-	switch tk.currentToken {
-	case "<":
-		return "&lt;"
-	case ">":
-		return "&gt;"
-	case "&":
-		return "&amp;"
-	default:
-		return tk.currentToken
-	}
+	return tk.currentToken.Text
 }
 
 func (tk *Tokenizer) Identifier() string {
-	return tk.currentToken
+	return tk.currentToken.Text
 }
 
+// IntVal returns the value of the current integer constant, or 0 if the
+// current token is not an integer constant.
 func (tk *Tokenizer) IntVal() int {
-	i, err := strconv.Atoi(tk.currentToken)
-	if err != nil {
-		log.Fatalln(err, tk.currentToken)
+	if tk.currentToken.Kind != IntConst {
+		return 0
 	}
+	i, _ := strconv.Atoi(tk.currentToken.Text)
 	return i
 }
 
+// StringVal returns the contents of the current string constant, or "" if
+// the current token is not a string constant.
 func (tk *Tokenizer) StringVal() string {
-	stringConstant, ok := tk.mapOfString[tk.currentToken]
-	if ok {
-		return stringConstant
+	if tk.currentToken.Kind == StringConst {
+		return tk.currentToken.Text
 	}
-	log.Fatalln("Token is not registered hashmap: ", tk.currentToken)
 	return ""
 }
 
+// CheckNextToken returns the source text of the next token without advancing.
+// String constants keep their quotes so that they are never mistaken for a
+// keyword or symbol.
 func (tk *Tokenizer) CheckNextToken() string {
 	if tk.index >= len(tk.tokens) {
-		fmt.Println("There is no next token")
 		return ""
 	}
-	i := tk.index
-	return tk.tokens[i]
-}
-
-func removeMultiLineComment(b []byte) []byte {
-	comment := regexp.MustCompile(`/\*.*?\*/`)
-	return comment.ReplaceAll(b, []byte(` `))
-}
-
-func removeOneLineComment(b []byte) []byte {
-	comment := regexp.MustCompile(`//.*\n`)
-	return comment.ReplaceAll(b, []byte(` `))
-}
-
-func removeNewLineAndTab(b []byte) []byte {
-	newLineAndTab := regexp.MustCompile(`\r\n|\r|\n|\t`)
-	return newLineAndTab.ReplaceAll(b, []byte(` `))
-}
-
-func replaceStringConstant(b []byte) ([]byte, map[string]string) {
-	stringConstant := regexp.MustCompile(`"(.*)"`)
-	found := stringConstant.FindAllStringSubmatch(string(b), -1)
-	mapOfStringConstant := make(map[string]string)
-
-	for i, f := range found {
-		s := fmt.Sprintf("StringConstant_%d", i)
-		mapOfStringConstant[s] = f[1]
-	}
-
-	inputText := string(b)
-	for k, v := range mapOfStringConstant {
-		s := strings.NewReplacer(v, k)
-		inputText = s.Replace(inputText)
+	next := tk.tokens[tk.index]
+	if next.Kind == StringConst {
+		return `"` + next.Text + `"`
 	}
-	s := strings.NewReplacer(`"`, ` `)
-	inputText = s.Replace(inputText)
-	return []byte(inputText), mapOfStringConstant
-}
-
-func replaceMultiSpaceToMonoSpace(s string) string {
-	multiSpace := regexp.MustCompile(` +`)
-	return multiSpace.ReplaceAllLiteralString(s, ` `)
+	return next.Text
 }
 
 func isSymbol(s string) bool {
@@ -231,9 +149,34 @@ func isKeyword(s string) bool {
 }
 
 func (tk *Tokenizer) GetCurrentToken() string {
+	return tk.currentToken.Text
+}
+
+// PeekToken returns the next token without advancing. At the end of the input
+// it returns a token of kind None positioned just after the last token.
+func (tk *Tokenizer) PeekToken() Token {
+	if tk.index < len(tk.tokens) {
+		return tk.tokens[tk.index]
+	}
+	end := Token{Kind: None, Line: 1, Column: 1}
+	if len(tk.tokens) > 0 {
+		last := tk.tokens[len(tk.tokens)-1]
+		width := len(last.Text)
+		if last.Kind == StringConst {
+			width += 2
+		}
+		end.Line = last.Line
+		end.Column = last.Column + width
+		end.Offset = last.Offset + width
+	}
+	return end
+}
+
+// CurrentToken returns the current token with its source position.
+func (tk *Tokenizer) CurrentToken() Token {
 	return tk.currentToken
 }
 
-func (tk *Tokenizer) GetTokens() []string {
+func (tk *Tokenizer) GetTokens() []Token {
 	return tk.tokens
 }
@@ -1,108 +1,68 @@
 package jacktokenizer
 
 import (
-	"fmt"
-	_ "io/ioutil"
-	"log"
 	"os"
-	_ "strings"
+	"strings"
 	"testing"
 )
 
-var mapOfTokenType = map[TokenTypes]string{
-	Keyword:     "keyword",
-	Symbol:      "symbol",
-	Identifier:  "identifier",
-	IntConst:    "integerConst",
-	StringConst: "stringConst",
-	None:        "none",
-}
-
-/*
-var mapOfKeyword = map[KeywordTypes]string{
-	_class:       "class",
-	_constructor: "constructor",
-	_function:    "function",
-	_method:      "method",
-	_field:       "field",
-	_static:      "static",
-	_var:         "var",
-	_int:         "int",
-	_char:        "char",
-	_boolean:     "boolean",
-	_void:        "void",
-	_true:        "true",
-	_false:       "false",
-	_null:        "null",
-	_this:        "this",
-	_let:         "let",
-	_do:          "do",
-	_if:          "if",
-	_else:        "else",
-	_while:       "while",
-	_return:      "return"}
-
-func TestTokenizer(t *testing.T) {
-	file, err := os.Open("../../ArrayTest/Main.jack")
+func TestGetTokens(t *testing.T) {
+	file, err := os.Open("../testcases/Square/SquareGame.jack")
 	if err != nil {
-		log.Fatalln(err)
+		t.Fatal(err)
 	}
 	defer file.Close()
-
-	tyFile, err := os.Open("../TokenType.txt")
+	tk, err := NewTokenizer(file)
 	if err != nil {
-		log.Fatalln(err)
+		t.Fatal(err)
 	}
-	defer tyFile.Close()
-	b, _ := ioutil.ReadAll(tyFile)
-	tyText := string(b)
-	tyInfo := strings.Split(tyText, "\n")
-
-	tk := NewTokenizer(file)
-	log.Println(tk.GetTokens())
+	tokens := tk.GetTokens()
+	if len(tokens) == 0 || tokens[0].Text != "class" || tokens[1].Text != "SquareGame" {
+		t.Errorf("\nactual: %v\nexpect: class SquareGame ...\n", tokens)
+	}
+}
 
-	for _, tyi := range tyInfo {
-		if !tk.HasMoreTokens() {
-			break
-		}
-		tk.Advance()
-		actual := mapOfTokenType[tk.TokenType()]
-		expect := tyi
+func TestScanPositions(t *testing.T) {
+	src := "class Main {\n  /** doc\n   */ field int x; // comment\n  let s = \"a // b\";\n}\n"
+	tokens, err := NewScanner(strings.NewReader(src)).ScanAll()
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		switch tk.TokenType() {
-		case Keyword:
-			fmt.Println(mapOfKeyword[tk.Keyword()])
-		case Symbol:
-			fmt.Println(tk.Symbol())
-		case StringConst:
-			fmt.Println(tk.StringVal())
-		case IntConst:
-			fmt.Println(tk.IntVal())
-		case Identifier:
-			fmt.Println(tk.Identifier())
-		case None:
-			fmt.Println("This is not token. Maybe space.")
-		default:
-			t.Errorf(`\nUnknown token type: %v\n`, tk.GetCurrentToken())
-		}
-		if actual != expect {
-			t.Errorf("\ntoken: %v\nactual: %v\nexpect: %v\n)",
-				tk.GetCurrentToken(), actual, expect)
+	expects := []Token{
+		{Keyword, "class", 1, 1, 0},
+		{Identifier, "Main", 1, 7, 6},
+		{Symbol, "{", 1, 12, 11},
+		{Keyword, "field", 3, 7, 29},
+		{Keyword, "int", 3, 13, 35},
+		{Identifier, "x", 3, 17, 39},
+		{Symbol, ";", 3, 18, 40},
+		{Keyword, "let", 4, 3, 55},
+		{Identifier, "s", 4, 7, 59},
+		{Symbol, "=", 4, 9, 61},
+		{StringConst, "a // b", 4, 11, 63},
+		{Symbol, ";", 4, 19, 71},
+		{Symbol, "}", 5, 1, 73},
+	}
+	if len(tokens) != len(expects) {
+		t.Fatalf("\nactual: %v\nexpect: %v\n", tokens, expects)
+	}
+	for i, expect := range expects {
+		if tokens[i] != expect {
+			t.Errorf("\nactual: %+v\nexpect: %+v\n", tokens[i], expect)
 		}
 	}
 }
-*/
 
-func TestCommaOkIdiom(t *testing.T) {
-	v, ok := mapOfTokenType[Keyword]
-	fmt.Println(v, ok)
-}
-
-func TestGetTokens(t *testing.T) {
-	file, err := os.Open("../../Square/SquareGame.jack")
-	if err != nil {
-		log.Fatalln(err)
+func TestScanErrors(t *testing.T) {
+	for _, src := range []string{
+		"let s = \"unterminated;\n",
+		"/* unterminated",
+		"let x = 32768;",
+		"let x = #;",
+	} {
+		if _, err := NewScanner(strings.NewReader(src)).ScanAll(); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
 	}
-	tk := NewTokenizer(file)
-	fmt.Println(tk.GetTokens())
 }
@@ -0,0 +1,242 @@
+package jacktokenizer
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// maxIntConst is the largest integer constant allowed by the Jack grammar.
+const maxIntConst = 32767
+
+// Token is a single lexical element of a Jack source file together with the
+// place it was read from. Line and Column start at 1, Offset is the byte
+// offset of the first character of the token.
+type Token struct {
+	Kind   TokenTypes
+	Text   string
+	Line   int
+	Column int
+	Offset int
+}
+
+// Scanner reads Jack source character by character and produces tokens.
+// Filename is only used to label diagnostics.
+type Scanner struct {
+	Filename string
+	r        *bufio.Reader
+	line     int
+	column   int
+	offset   int
+}
+
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		r:      bufio.NewReader(r),
+		line:   1,
+		column: 1,
+		offset: 0}
+}
+
+// Scan returns the next token. It returns io.EOF when the input is exhausted.
+func (s *Scanner) Scan() (Token, error) {
+	for {
+		ch, err := s.peek()
+		if err != nil {
+			return Token{}, err
+		}
+		if isSpace(ch) {
+			s.read()
+			continue
+		}
+
+		tok := Token{Line: s.line, Column: s.column, Offset: s.offset}
+		switch {
+		case ch == '/':
+			s.read()
+			next, err := s.peek()
+			if err == nil && next == '/' {
+				if err := s.skipLineComment(); err != nil {
+					return Token{}, err
+				}
+				continue
+			}
+			if err == nil && next == '*' {
+				if err := s.skipBlockComment(tok); err != nil {
+					return Token{}, err
+				}
+				continue
+			}
+			tok.Kind = Symbol
+			tok.Text = "/"
+			return tok, nil
+		case ch == '"':
+			return s.scanString(tok)
+		case isDigit(ch):
+			return s.scanInteger(tok)
+		case isLetter(ch):
+			return s.scanWord(tok)
+		case isSymbol(string(ch)):
+			s.read()
+			tok.Kind = Symbol
+			tok.Text = string(ch)
+			return tok, nil
+		default:
+			s.read()
+			return Token{}, s.errorf(tok, "unexpected character %q", ch)
+		}
+	}
+}
+
+// ScanAll reads tokens until the end of the input.
+func (s *Scanner) ScanAll() ([]Token, error) {
+	tokens := []Token{}
+	for {
+		tok, err := s.Scan()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func (s *Scanner) scanString(tok Token) (Token, error) {
+	s.read() // opening '"'
+	text := []rune{}
+	for {
+		ch, err := s.read()
+		if err == io.EOF || ch == '\n' || ch == '\r' {
+			return Token{}, s.errorf(tok, "unterminated string constant")
+		}
+		if err != nil {
+			return Token{}, err
+		}
+		if ch == '"' {
+			break
+		}
+		text = append(text, ch)
+	}
+	tok.Kind = StringConst
+	tok.Text = string(text)
+	return tok, nil
+}
+
+func (s *Scanner) scanInteger(tok Token) (Token, error) {
+	text, err := s.readWhile(isDigit)
+	if err != nil {
+		return Token{}, err
+	}
+	if i, err := strconv.Atoi(text); err != nil || i > maxIntConst {
+		return Token{}, s.errorf(tok, "integer constant %v is out of range", text)
+	}
+	tok.Kind = IntConst
+	tok.Text = text
+	return tok, nil
+}
+
+func (s *Scanner) scanWord(tok Token) (Token, error) {
+	text, err := s.readWhile(func(ch rune) bool {
+		return isLetter(ch) || isDigit(ch)
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	tok.Kind = Identifier
+	if isKeyword(text) {
+		tok.Kind = Keyword
+	}
+	tok.Text = text
+	return tok, nil
+}
+
+func (s *Scanner) skipLineComment() error {
+	for {
+		ch, err := s.read()
+		if err == io.EOF || ch == '\n' {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// skipBlockComment skips both "/* */" and "/** */" comments. The leading '/'
+// has already been consumed; start is the position of that '/'.
+func (s *Scanner) skipBlockComment(start Token) error {
+	s.read() // '*'
+	star := false
+	for {
+		ch, err := s.read()
+		if err == io.EOF {
+			return s.errorf(start, "unterminated comment")
+		}
+		if err != nil {
+			return err
+		}
+		if star && ch == '/' {
+			return nil
+		}
+		star = ch == '*'
+	}
+}
+
+func (s *Scanner) readWhile(accept func(rune) bool) (string, error) {
+	text := []rune{}
+	for {
+		ch, err := s.peek()
+		if err == io.EOF || (err == nil && !accept(ch)) {
+			return string(text), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		s.read()
+		text = append(text, ch)
+	}
+}
+
+func (s *Scanner) read() (rune, error) {
+	ch, size, err := s.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	s.offset += size
+	if ch == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+	return ch, nil
+}
+
+func (s *Scanner) peek() (rune, error) {
+	ch, _, err := s.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	s.r.UnreadRune()
+	return ch, nil
+}
+
+func (s *Scanner) errorf(at Token, format string, args ...interface{}) error {
+	return diagnostic.Errorf(s.Filename, at.Line, at.Column, diagnostic.CodeLexical, format, args...)
+}
+
+func isSpace(ch rune) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v'
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
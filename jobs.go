@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compiler"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// job is the build of one class. What it prints is collected while it runs
+// and printed once it is done, in the order of the jobs, so that the output
+// does not depend on the scheduling.
+type job struct {
+	program  *program
+	compiler *compiler.Compiler
+	file     string
+
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	// outputs are the names of the files written, by artifact.
+	outputs map[string]string
+	err     error
+	done    chan struct{}
+}
+
+// runJobs runs jobs on b.jobs workers. A worker has one class and its
+// outputs open at a time, which bounds the number of open files.
+func (b *buildFlags) runJobs(jobs []*job, stage compiler.Stage, emit map[string]bool) {
+	queue := make(chan *job)
+	for _, j := range jobs {
+		j.done = make(chan struct{})
+	}
+	for i := 0; i < b.jobs && i < len(jobs); i++ {
+		go func() {
+			for j := range queue {
+				j.outputs, j.err = b.buildFile(j, stage, emit)
+				if _, ok := j.err.(diagnostic.List); j.err != nil && !ok {
+					fmt.Fprintln(&j.stderr, j.err)
+				}
+				close(j.done)
+			}
+		}()
+	}
+	go func() {
+		for _, j := range jobs {
+			queue <- j
+		}
+		close(queue)
+	}()
+
+	for _, j := range jobs {
+		<-j.done
+		os.Stdout.Write(j.stdout.Bytes())
+		os.Stderr.Write(j.stderr.Bytes())
+	}
+}
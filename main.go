@@ -1,65 +1,86 @@
 package main
 
 import (
-	"./compilationengine"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
-	"path/filepath"
 )
 
-func main() {
-	jackFileNames := []string{}
-
-	arg := getArg(os.Args)
-
-	fInfo, err := os.Stat(arg)
-	if err != nil {
-		fmt.Println("File information about argument cannot be got")
-	}
+// Exit codes of the commands.
+const (
+	exitOK = 0
+	// exitFailure means that the sources have errors or that an output could
+	// not be written.
+	exitFailure = 1
+	// exitUsage means that the command line is invalid.
+	exitUsage = 2
+	// exitRuntime means that a program failed in the VM emulator.
+	exitRuntime = 3
+)
 
-	if fInfo.IsDir() {
-		jackFileNames, err = filepath.Glob(fmt.Sprintf("%v*.jack", arg))
-	} else {
-		if filepath.Ext(arg) != ".jack" {
-			log.Fatalln("Argument is not jack file")
-		}
-		jackFileNames = append(jackFileNames, arg)
-	}
+const usage = `usage: rewriting-JackCompiler [command] [flags] <file.jack|dir>...
 
-	for _, file := range jackFileNames {
-		fmt.Println(file)
-		NameOfXML := fmt.Sprintf("%v_.xml", file[:len(file)-5])
-		outputXmlFile, err := os.Create(NameOfXML)
-		if err != nil {
-			log.Fatalln(err)
-		}
-		defer outputXmlFile.Close()
+commands:
+  compile    compile Jack classes into VM code (the default)
+  tokenize   write the tokens of Jack classes as xxxT.xml files
+  parse      write the syntax trees of Jack classes
+  check      check Jack classes without writing anything
+  run        compile a program and run it in the VM emulator
+  watch      compile Jack classes again whenever they change
+  translate  translate VM code into Hack assembly
+  assemble   assemble Hack assembly into machine code
 
-		inputFile, err := os.Open(file)
-		if err != nil {
-			log.Fatalln(err)
-		}
-		defer inputFile.Close()
+Run "rewriting-JackCompiler <command> -h" for the flags of a command.
+The exit status is 0 on success, 1 on errors, 2 on invalid usage and 3 when
+a program run fails.
+`
 
-        outputFile, err := os.Create(fmt.Sprintf("%v_.vm", file[:len(file)-5]))
-        if err != nil {
-            log.Fatalln(err)
-        }
-        defer outputFile.Close()
+var commands = map[string]bool{
+	"compile":   true,
+	"tokenize":  true,
+	"parse":     true,
+	"check":     true,
+	"run":       true,
+	"watch":     true,
+	"translate": true,
+	"assemble":  true,
+	"help":      true,
+}
 
-		ce := compilationengine.NewCompilationEngine(inputFile, outputFile, outputXmlFile)
-		ce.CompileClass()
+// inputStatus returns the exit status of an error finding the inputs of a
+// command: a file that cannot be read is a failure, while an argument of the
+// wrong kind is invalid usage.
+func inputStatus(err error) int {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return exitFailure
 	}
+	return exitUsage
 }
 
-func getArg(names []string) string {
-	if len(names) <= 1 {
-		log.Fatalln("Arguments get error: No arg is given")
-	} else if len(names) == 2 {
-		return names[1]
-	} else {
-		log.Fatalln("Arguments get error: Too many arguments are given")
+func main() {
+	// Without a command, the arguments are those of compile.
+	args := os.Args[1:]
+	command := "compile"
+	if len(args) > 0 && commands[args[0]] {
+		command, args = args[0], args[1:]
+	} else if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		command = "help"
+	}
+
+	switch command {
+	case "compile", "tokenize", "parse", "check":
+		os.Exit(build(command, args))
+	case "run":
+		os.Exit(run(args))
+	case "watch":
+		os.Exit(watch(args))
+	case "translate":
+		os.Exit(translate(args))
+	case "assemble":
+		os.Exit(assemble(args))
+	case "help":
+		fmt.Print(usage)
 	}
-	return ""
 }
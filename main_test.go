@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compiler"
+)
+
+const (
+	printSeven  = "class Main {\n  function void main() {\n    do Output.printInt(7);\n    return;\n  }\n}\n"
+	readNumber  = "class Main {\n  function void main() {\n    do Output.printInt(Keyboard.readInt(\"n? \"));\n    return;\n  }\n}\n"
+	loopForever = "class Main {\n  function void main() {\n    while (true) {\n    }\n    return;\n  }\n}\n"
+	syntaxError = "class Main {\n  function void main() {\n    let x = ;\n    return;\n  }\n}\n"
+)
+
+// writeTree writes the files of tree, named by their slash-separated paths,
+// under a temporary directory and returns the directory.
+func writeTree(t *testing.T, tree map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range tree {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// silently runs a command with the standard output and error discarded and
+// returns its exit status.
+func silently(t *testing.T, command func() int) int {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	return command()
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"Seven/Main.jack":  printSeven,
+		"Broken/Main.jack": syntaxError,
+		"Loop/Main.jack":   loopForever,
+		"Read/Main.jack":   readNumber,
+		"keys.txt":         "42\n",
+		"notes.txt":        "",
+	})
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	cases := []struct {
+		name    string
+		command func() int
+		status  int
+	}{
+		{"compile", func() int { return build("compile", []string{path("Seven")}) }, exitOK},
+		{"compile errors", func() int { return build("compile", []string{path("Broken")}) }, exitFailure},
+		{"compile missing file", func() int { return build("compile", []string{path("Missing.jack")}) }, exitFailure},
+		{"compile no argument", func() int { return build("compile", nil) }, exitUsage},
+		{"compile unknown flag", func() int { return build("compile", []string{"-x", path("Seven")}) }, exitUsage},
+		{"compile not jack", func() int { return build("compile", []string{path("notes.txt")}) }, exitUsage},
+		{"compile -q -v", func() int { return build("compile", []string{"-q", "-v", path("Seven")}) }, exitUsage},
+		{"check", func() int { return build("check", []string{path("Seven")}) }, exitOK},
+		{"run", func() int { return run([]string{path("Seven")}) }, exitOK},
+		{"run errors", func() int { return run([]string{path("Broken")}) }, exitFailure},
+		{"run missing input", func() int { return run([]string{"-input", path("missing.txt"), path("Read")}) }, exitFailure},
+		{"run input", func() int { return run([]string{"-input", path("keys.txt"), path("Read")}) }, exitOK},
+		{"run without input", func() int { return run([]string{path("Read")}) }, exitRuntime},
+		{"run step limit", func() int { return run([]string{"-steps", "1000", path("Loop")}) }, exitRuntime},
+		{"run two arguments", func() int { return run([]string{path("Seven"), path("Read")}) }, exitUsage},
+		{"translate no argument", func() int { return translate(nil) }, exitUsage},
+		{"translate missing file", func() int { return translate([]string{path("Missing.vm")}) }, exitFailure},
+		{"translate not vm", func() int { return translate([]string{path("notes.txt")}) }, exitUsage},
+		{"assemble not asm", func() int { return assemble([]string{path("notes.txt")}) }, exitUsage},
+	}
+	for _, c := range cases {
+		if status := silently(t, c.command); status != c.status {
+			t.Errorf("%v: exit status %v, expect %v", c.name, status, c.status)
+		}
+	}
+}
+
+func TestOutputNames(t *testing.T) {
+	p := &program{dir: filepath.Join("src", "game"), root: "src"}
+	file := filepath.Join("src", "game", "Main.jack")
+	cases := []struct {
+		outDir, suffix, artifact string
+		name, dir                string
+	}{
+		{"", "", "vm", "src/game/Main.vm", "src/game"},
+		{"", "_", "xml", "src/game/Main_.xml", "src/game"},
+		{"", "", "txml", "src/game/MainT.xml", "src/game"},
+		{"out", "", "vm", "out/game/Main.vm", "out/game"},
+		{"out", "_", "symbols", "out/game/Main_.symbols", "out/game"},
+	}
+	for _, c := range cases {
+		b := &buildFlags{outDir: c.outDir, suffix: c.suffix}
+		if name := b.outputName(p.root, file, c.artifact); name != filepath.FromSlash(c.name) {
+			t.Errorf("-o %q -suffix %q %v: %v, expect %v", c.outDir, c.suffix, c.artifact, name, c.name)
+		}
+		if dir := b.outputDir(p); dir != filepath.FromSlash(c.dir) {
+			t.Errorf("-o %q: program outputs in %v, expect %v", c.outDir, dir, c.dir)
+		}
+	}
+}
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Main.vm")
+	if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := createAtomic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("partial")
+	f.Abort()
+	if data, _ := os.ReadFile(name); string(data) != "old" {
+		t.Errorf("aborted write replaced the file with %q", data)
+	}
+
+	f, err = createAtomic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new")
+	if data, _ := os.ReadFile(name); string(data) != "old" {
+		t.Errorf("the file holds %q before the commit", data)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "new" {
+		t.Errorf("the file holds %q after the commit", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+}
+
+func TestHackUnderOutputDirectory(t *testing.T) {
+	dir := writeTree(t, map[string]string{"Loop/Main.jack": loopForever})
+	src, out := filepath.Join(dir, "Loop"), filepath.Join(dir, "out")
+	if status := silently(t, func() int { return build("compile", []string{"-hack", "-o", out, src}) }); status != exitOK {
+		t.Fatalf("exit status %v", status)
+	}
+	for _, name := range []string{"Main.vm", "Loop.asm", "Loop.hack"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(filepath.Join(src, name)); err == nil {
+			t.Errorf("%v written next to the sources", name)
+		}
+	}
+}
+
+func TestCollectPrograms(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"Main.jack":            printSeven,
+		"Util.jack":            printSeven,
+		"game/Main.jack":       printSeven,
+		"game/test/Check.jack": printSeven,
+		"lib/Math2.jack":       printSeven,
+		".hidden/Secret.jack":  printSeven,
+		"notes.txt":            "",
+	})
+	cases := []struct {
+		name   string
+		args   []string
+		filter sourceFilter
+		// programs lists the files built, by directory, as slash-separated
+		// paths relative to dir.
+		programs map[string][]string
+	}{
+		{"directory", []string{"."}, sourceFilter{},
+			map[string][]string{".": {"Main.jack", "Util.jack"}}},
+		{"recursive", []string{"."}, sourceFilter{recursive: true},
+			map[string][]string{
+				".":         {"Main.jack", "Util.jack"},
+				"game":      {"game/Main.jack"},
+				"game/test": {"game/test/Check.jack"},
+				"lib":       {"lib/Math2.jack"}}},
+		{"exclude", []string{"."}, sourceFilter{recursive: true, exclude: patternList{"test", "Util.jack"}},
+			map[string][]string{
+				".":    {"Main.jack"},
+				"game": {"game/Main.jack"},
+				"lib":  {"lib/Math2.jack"}}},
+		{"include", []string{"."}, sourceFilter{recursive: true, include: patternList{"game/*"}},
+			map[string][]string{"game": {"game/Main.jack"}}},
+		{"files", []string{"game/Main.jack", "Util.jack", "Main.jack"}, sourceFilter{exclude: patternList{"*.jack"}},
+			map[string][]string{
+				".":    {"Main.jack", "Util.jack"},
+				"game": {"game/Main.jack"}}},
+		{"duplicates", []string{".", "Main.jack"}, sourceFilter{},
+			map[string][]string{".": {"Main.jack", "Util.jack"}}},
+	}
+	for _, c := range cases {
+		var args []string
+		for _, arg := range c.args {
+			args = append(args, filepath.Join(dir, filepath.FromSlash(arg)))
+		}
+		programs, err := collectPrograms(args, c.filter)
+		if err != nil {
+			t.Errorf("%v: %v", c.name, err)
+			continue
+		}
+		actual := map[string][]string{}
+		for _, p := range programs {
+			rel, _ := filepath.Rel(dir, p.dir)
+			for _, file := range p.files {
+				name, _ := filepath.Rel(dir, file)
+				actual[filepath.ToSlash(rel)] = append(actual[filepath.ToSlash(rel)], filepath.ToSlash(name))
+			}
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(c.programs) {
+			t.Errorf("%v:\nactual: %v\nexpect: %v\n", c.name, actual, c.programs)
+		}
+	}
+
+	programs, _ := collectPrograms([]string{filepath.Join(dir, "game", "Main.jack")}, sourceFilter{})
+	if len(programs) != 1 || len(programs[0].classes) != 1 {
+		t.Errorf("unexpected programs: %+v", programs)
+	}
+	if _, err := collectPrograms([]string{filepath.Join(dir, "notes.txt")}, sourceFilter{}); err == nil {
+		t.Error("expected an error for a file that is not a jack file")
+	}
+}
+
+// stdoutOf runs a command and returns what it prints to the standard output,
+// the standard error being discarded.
+func stdoutOf(t *testing.T, command func() int) string {
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	silently(t, func() int {
+		os.Stdout = out
+		return command()
+	})
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestJobsPrintInOrder(t *testing.T) {
+	tree := map[string]string{}
+	var expect strings.Builder
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("C%02d", i)
+		tree[name+".jack"] = "class " + name + " {\n  function void f() {\n    return;\n  }\n}\n"
+	}
+	dir := writeTree(t, tree)
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&expect, "%v\n", filepath.Join(dir, fmt.Sprintf("C%02d.jack", i)))
+	}
+	for _, jobs := range []string{"1", "8"} {
+		actual := stdoutOf(t, func() int { return build("check", []string{"-j", jobs, dir}) })
+		if actual != expect.String() {
+			t.Errorf("-j %v:\nactual:\n%v\nexpect:\n%v\n", jobs, actual, expect.String())
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	helper := "class Helper {\n  function int one() {\n    return 1;\n  }\n}\n"
+	dir := writeTree(t, map[string]string{"Main.jack": printSeven, "Helper.jack": helper})
+	main := filepath.Join(dir, "Main.jack")
+	key := func(b *buildFlags, stage compiler.Stage, emit string, src string) string {
+		p := &program{dir: dir, root: dir, files: []string{main}, classes: []string{filepath.Join(dir, "Helper.jack"), main}}
+		c, err := b.newCompiler(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set, _ := parseEmit(emit, stage)
+		return b.cacheKey(&job{program: p, compiler: c, file: main}, stage, set, []byte(src))
+	}
+	base := key(&buildFlags{}, compiler.Generate, "vm", printSeven)
+	if key(&buildFlags{}, compiler.Generate, "vm", printSeven) != base {
+		t.Error("the key of the same build changes")
+	}
+	if key(&buildFlags{outDir: "out", suffix: "_", jobs: 4}, compiler.Generate, "vm", printSeven) != base {
+		t.Error("the key depends on flags the outputs do not depend on")
+	}
+	for name, changed := range map[string]string{
+		"source":  key(&buildFlags{}, compiler.Generate, "vm", printSeven+"\n"),
+		"options": key(&buildFlags{opt: 1}, compiler.Generate, "vm", printSeven),
+		"emit":    key(&buildFlags{}, compiler.Generate, "vm,xml", printSeven),
+		"stage":   key(&buildFlags{}, compiler.Check, "", printSeven),
+	} {
+		if changed == base {
+			t.Errorf("the key does not change with the %v", name)
+		}
+	}
+
+	// Only the signatures of the other classes count.
+	os.WriteFile(filepath.Join(dir, "Helper.jack"), []byte(strings.Replace(helper, "return 1", "return 2", 1)), 0644)
+	if key(&buildFlags{}, compiler.Generate, "vm", printSeven) != base {
+		t.Error("the key changes with the body of another class")
+	}
+	os.WriteFile(filepath.Join(dir, "Helper.jack"), []byte(strings.Replace(helper, "one()", "one(int x)", 1)), 0644)
+	if key(&buildFlags{}, compiler.Generate, "vm", printSeven) == base {
+		t.Error("the key does not change with the signatures of another class")
+	}
+}
+
+func TestWriteIfChanged(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "Main.vm")
+	for _, c := range []struct {
+		data    string
+		written bool
+	}{
+		{"push constant 1\n", true},
+		{"push constant 1\n", false},
+		{"push constant 2\n", true},
+	} {
+		written, err := writeIfChanged(name, []byte(c.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(name); written != c.written || string(data) != c.data {
+			t.Errorf("writing %q: written %v, file %q", c.data, written, data)
+		}
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	helper := "class Helper {\n  function int one() {\n    return 1;\n  }\n}\n"
+	dir := writeTree(t, map[string]string{"Main.jack": printSeven, "Helper.jack": helper})
+	b := &buildFlags{}
+	w := &watcher{stamps: map[string]stamp{}, dirs: map[string]string{}, digests: map[string]string{}}
+	poll := func() []string {
+		programs, err := collectPrograms([]string{dir}, sourceFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rebuilt []string
+		for _, j := range w.poll(b, programs) {
+			rebuilt = append(rebuilt, filepath.Base(j.file))
+		}
+		return rebuilt
+	}
+	write := func(name, src string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		change  func()
+		rebuilt string
+	}{
+		{func() {}, "[Helper.jack Main.jack]"},
+		{func() {}, "[]"},
+		{func() { write("Helper.jack", strings.Replace(helper, "return 1", "return 12", 1)) }, "[Helper.jack]"},
+		{func() { write("Helper.jack", strings.Replace(helper, "one()", "one(int x)", 1)) }, "[Helper.jack Main.jack]"},
+		{func() { write("Other.jack", "class Other {\n}\n") }, "[Helper.jack Main.jack Other.jack]"},
+		{func() { os.Remove(filepath.Join(dir, "Other.jack")) }, "[Helper.jack Main.jack]"},
+	}
+	for i, step := range steps {
+		step.change()
+		if rebuilt := fmt.Sprint(poll()); rebuilt != step.rebuilt {
+			t.Errorf("poll %v: rebuilt %v, expect %v", i, rebuilt, step.rebuilt)
+		}
+	}
+}
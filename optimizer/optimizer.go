@@ -0,0 +1,264 @@
+package optimizer
+
+import (
+	. "github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+var (
+	pushFalse = Instruction{Op: Push, Segment: Constant}
+	not       = Instruction{Op: Not}
+)
+
+// Optimize returns code with the naive sequences emitted by the code
+// generator simplified, function by function, until none is left:
+//
+//   - constant conditions: "push constant 0; not" is true, so negating it or
+//     jumping on it is decided at compile time, and "not; not" cancels out;
+//   - a negated boolean condition jumping over a goto jumps to its target
+//     instead;
+//   - a while loop with a boolean condition is rotated to test it at the
+//     bottom, which jumps back while it holds instead of negating it to jump
+//     out;
+//   - array assignments of a single pushed value skip the copy through
+//     temp 0;
+//   - jumps to the next instruction, code that cannot be reached after return
+//     and goto, and labels nothing jumps to are removed.
+//
+// It relies on temp 0 being used as a scratch register only, as the code
+// generator does.
+func Optimize(code []Instruction) []Instruction {
+	var optimized []Instruction
+	for start := 0; start < len(code); {
+		end := start + 1
+		for end < len(code) && code[end].Op != Function {
+			end++
+		}
+		function := append([]Instruction{}, code[start:end]...)
+		for changed := true; changed; {
+			function, changed = optimizeOnce(function)
+			if !changed {
+				function, changed = rotateLoop(function)
+			}
+		}
+		optimized = append(optimized, function...)
+		start = end
+	}
+	return optimized
+}
+
+// optimizeOnce applies every rule once over the code of a function.
+func optimizeOnce(code []Instruction) ([]Instruction, bool) {
+	changed := false
+	var out []Instruction
+	for i := 0; i < len(code); i++ {
+		rest := code[i:]
+		switch {
+		case matches(rest, not, not):
+			i++
+
+		case isNegatedBranch(rest) && isBoolean(out, len(out)-1):
+			out = append(out, Instruction{Op: IfGoto, Label: rest[2].Label}, rest[3])
+			i += 3
+
+		case matches(rest, pushFalse, not) && len(rest) > 2 && rest[2].Op == IfGoto:
+			out = append(out, Instruction{Op: Goto, Label: rest[2].Label})
+			i += 2
+
+		case matches(rest, pushFalse) && len(rest) > 1 && rest[1].Op == IfGoto:
+			i++
+
+		case isArrayCopy(rest):
+			out = append(out, rest[2], rest[0], rest[4])
+			i += 4
+
+		case rest[0].Op == Goto && len(rest) > 1 && rest[1].Op == Label && rest[1].Label == rest[0].Label:
+
+		default:
+			out = append(out, rest[0])
+			continue
+		}
+		changed = true
+	}
+
+	out, removed := removeUnreachable(out)
+	return out, changed || removed
+}
+
+func matches(code []Instruction, pattern ...Instruction) bool {
+	if len(code) < len(pattern) {
+		return false
+	}
+	for i, in := range pattern {
+		if code[i] != in {
+			return false
+		}
+	}
+	return true
+}
+
+// isNegatedBranch matches "not; if-goto T; goto F; label T", which is
+// "if-goto F; label T" when the negated value is a boolean. For other values
+// it is not: "not" only turns -1 into 0.
+func isNegatedBranch(code []Instruction) bool {
+	return len(code) >= 4 && code[0] == not &&
+		code[1].Op == IfGoto && code[2].Op == Goto &&
+		code[3].Op == Label && code[3].Label == code[1].Label
+}
+
+// isBoolean reports whether the value pushed by code[end] is known to be 0
+// or -1: the result of a comparison, of false or true, or of a logical
+// operator on such values.
+func isBoolean(code []Instruction, end int) bool {
+	if end < 0 {
+		return false
+	}
+	switch in := code[end]; in.Op {
+	case Eq, Gt, Lt:
+		return true
+	case Push:
+		return in == pushFalse
+	case Not:
+		return isBoolean(code, end-1)
+	case And, Or:
+		right := operandStart(code, end-1)
+		return right > 0 && isBoolean(code, end-1) && isBoolean(code, right-1)
+	}
+	return false
+}
+
+// operandStart returns the index of the first instruction of the code that
+// pushes the value of code[end], or -1 if it cannot be told.
+func operandStart(code []Instruction, end int) int {
+	need := 1
+	for i := end; i >= 0; i-- {
+		pushes, pops, ok := stackEffect(code[i])
+		if !ok {
+			return -1
+		}
+		need += pops - pushes
+		if need == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// stackEffect returns the number of values in pushes and pops from the
+// stack, or false for the instructions that jump or define a label.
+func stackEffect(in Instruction) (pushes, pops int, ok bool) {
+	switch in.Op {
+	case Push:
+		return 1, 0, true
+	case Pop:
+		return 0, 1, true
+	case Add, Sub, And, Or, Eq, Gt, Lt:
+		return 1, 2, true
+	case Neg, Not:
+		return 1, 1, true
+	case Call:
+		return 1, in.NArgs, true
+	}
+	return 0, 0, false
+}
+
+// rotateLoop rewrites the first loop
+//
+//	label L; cond; not; if-goto E; body; goto L; label E
+//
+// whose condition is a boolean into
+//
+//	goto L; label B; body; label L; cond; if-goto B; label E
+//
+// which neither negates the condition nor jumps back through a goto at each
+// iteration. It reports whether a loop was rotated.
+func rotateLoop(code []Instruction) ([]Instruction, bool) {
+	labels := map[string]bool{}
+	for _, in := range code {
+		if in.Op == Label {
+			labels[in.Label] = true
+		}
+	}
+	for i, in := range code {
+		if in.Op != Label {
+			continue
+		}
+		j := i + 1
+		for j < len(code) {
+			if _, _, ok := stackEffect(code[j]); !ok {
+				break
+			}
+			j++
+		}
+		// code[i+1:j-1] is the condition and code[j-1] the not.
+		if j == len(code) || j-2 <= i || code[j-1] != not || code[j].Op != IfGoto ||
+			operandStart(code, j-2) != i+1 || !isBoolean(code, j-2) {
+			continue
+		}
+		end := code[j].Label
+		k := j + 1
+		for k < len(code) && !(code[k].Op == Label && code[k].Label == end) {
+			k++
+		}
+		body := in.Label + "_BODY"
+		if k == len(code) || code[k-1] != (Instruction{Op: Goto, Label: in.Label}) || labels[body] {
+			continue
+		}
+
+		var out []Instruction
+		out = append(out, code[:i]...)
+		out = append(out, Instruction{Op: Goto, Label: in.Label}, Instruction{Op: Label, Label: body})
+		out = append(out, code[j+1:k-1]...)
+		out = append(out, in)
+		out = append(out, code[i+1:j-1]...)
+		out = append(out, Instruction{Op: IfGoto, Label: body})
+		out = append(out, code[k:]...)
+		return out, true
+	}
+	return code, false
+}
+
+// isArrayCopy matches "push X; pop temp 0; pop pointer 1; push temp 0; pop
+// that 0", where pushing X does not depend on pointer 1.
+func isArrayCopy(code []Instruction) bool {
+	if len(code) < 5 || code[0].Op != Push || code[0].Segment == That || code[0] == (Instruction{Op: Push, Segment: Pointer, Index: 1}) {
+		return false
+	}
+	return matches(code[1:],
+		Instruction{Op: Pop, Segment: Temp},
+		Instruction{Op: Pop, Segment: Pointer, Index: 1},
+		Instruction{Op: Push, Segment: Temp},
+		Instruction{Op: Pop, Segment: That})
+}
+
+// removeUnreachable removes the code following a return or a goto up to the
+// next label something jumps to, and the labels nothing jumps to.
+func removeUnreachable(code []Instruction) ([]Instruction, bool) {
+	targets := map[string]bool{}
+	for _, in := range code {
+		if in.Op == Goto || in.Op == IfGoto {
+			targets[in.Label] = true
+		}
+	}
+
+	var out []Instruction
+	reachable := true
+	for _, in := range code {
+		switch in.Op {
+		case Label:
+			if !targets[in.Label] {
+				continue
+			}
+			reachable = true
+		case Function:
+			reachable = true
+		}
+		if !reachable {
+			continue
+		}
+		out = append(out, in)
+		if in.Op == Return || in.Op == Goto {
+			reachable = false
+		}
+	}
+	return out, len(out) != len(code)
+}
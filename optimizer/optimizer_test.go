@@ -0,0 +1,225 @@
+package optimizer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compilationengine"
+	"github.com/jinpei0908/rewriting-JackCompiler/jackos"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+	. "github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+func optimizeString(t *testing.T, src string) string {
+	code, err := Parse("Foo.vm", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	Write(&out, Optimize(code))
+	return out.String()
+}
+
+func TestOptimize(t *testing.T) {
+	actual := optimizeString(t, `function Foo.bar 1
+label WHILE_EXP0
+push constant 0
+not
+not
+if-goto WHILE_END0
+push local 0
+push constant 1
+add
+push constant 7
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+push local 0
+push constant 0
+eq
+not
+if-goto IF_TRUE0
+goto IF_FALSE0
+label IF_TRUE0
+push constant 0
+not
+if-goto WHILE_EXP0
+push constant 1
+label IF_FALSE0
+goto WHILE_EXP0
+label WHILE_END0
+push constant 0
+return
+push constant 1
+return
+function Foo.baz 0
+push constant 0
+return
+`)
+	expect := `function Foo.bar 1
+label WHILE_EXP0
+push local 0
+push constant 1
+add
+pop pointer 1
+push constant 7
+pop that 0
+push local 0
+push constant 0
+eq
+if-goto IF_FALSE0
+goto WHILE_EXP0
+label IF_FALSE0
+goto WHILE_EXP0
+function Foo.baz 0
+push constant 0
+return
+`
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestOptimizeKeepsNegatedIntegers(t *testing.T) {
+	// ~1 is -2, which is true, while 1 is true too: jumping on 1 instead of
+	// negating it would take the other branch.
+	src := `function Foo.bar 0
+push argument 0
+not
+if-goto IF_TRUE0
+goto IF_FALSE0
+label IF_TRUE0
+push constant 1
+return
+label IF_FALSE0
+push constant 2
+return
+`
+	if actual := optimizeString(t, src); actual != src {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, src)
+	}
+}
+
+func TestOptimizeRotatesWhileLoops(t *testing.T) {
+	actual := optimizeString(t, `function Foo.count 1
+label WHILE_EXP0
+push local 0
+push argument 0
+lt
+not
+if-goto WHILE_END0
+push local 0
+push constant 1
+add
+pop local 0
+goto WHILE_EXP0
+label WHILE_END0
+label WHILE_EXP1
+push argument 1
+not
+if-goto WHILE_END1
+push constant 0
+pop argument 1
+goto WHILE_EXP1
+label WHILE_END1
+push local 0
+return
+`)
+	// The second condition is not a boolean, so its loop is kept.
+	expect := `function Foo.count 1
+goto WHILE_EXP0
+label WHILE_EXP0_BODY
+push local 0
+push constant 1
+add
+pop local 0
+label WHILE_EXP0
+push local 0
+push argument 0
+lt
+if-goto WHILE_EXP0_BODY
+label WHILE_EXP1
+push argument 1
+not
+if-goto WHILE_END1
+push constant 0
+pop argument 1
+goto WHILE_EXP1
+label WHILE_END1
+push local 0
+return
+`
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+// runProgram compiles a testcases directory, optimized or not, and returns
+// what it prints.
+func runProgram(t *testing.T, name string, optimize bool) string {
+	dir := t.TempDir()
+	sources, _ := filepath.Glob(filepath.Join("../testcases", name, "*.jack"))
+	if len(sources) == 0 {
+		t.Fatalf("no jack files in testcases/%v", name)
+	}
+	m := vmemu.New()
+	for _, source := range sources {
+		inputFile, err := os.Open(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputFile, err := os.Create(filepath.Join(dir, "out.vm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		debugFile, err := os.Create(filepath.Join(dir, "out.xml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ce := compilationengine.NewCompilationEngine(inputFile, outputFile, debugFile)
+		ce.SetFolding(optimize)
+		if optimize {
+			ce.SetOptimizer(Optimize)
+		}
+		err = ce.CompileClass()
+		inputFile.Close()
+		outputFile.Close()
+		debugFile.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vm, err := os.ReadFile(filepath.Join(dir, "out.vm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Load(filepath.Base(source), bytes.NewReader(vm)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jackOS := jackos.New()
+	jackOS.Install(m)
+	jackOS.Type("3\n10\n-4\n7\n")
+	m.RAM[8000] = 1234
+	m.MaxSteps = 1000000
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 8001; i <= 8016; i++ {
+		jackOS.Output.WriteString(string('0' + rune(m.RAM[i]&1)))
+	}
+	return jackOS.Output.String()
+}
+
+func TestOptimizePreservesBehavior(t *testing.T) {
+	for _, name := range []string{"Seven", "ConvertToBin", "Average", "ComplexArrays"} {
+		if naive, optimized := runProgram(t, name, false), runProgram(t, name, true); naive != optimized {
+			t.Errorf("%v:\nnaive:\n%v\noptimized:\n%v\n", name, naive, optimized)
+		}
+	}
+}
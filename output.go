@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is an output file written under a temporary name in its
+// directory, then renamed by Commit, so that a failed or interrupted write
+// never leaves a partial file, nor replaces the previous one.
+type atomicFile struct {
+	*os.File
+	name string
+}
+
+func createAtomic(name string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, name: name}, nil
+}
+
+// Commit closes the file and moves it to its name.
+func (f *atomicFile) Commit() error {
+	err := f.Chmod(0644)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.File.Name(), f.name)
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+	}
+	return err
+}
+
+// Abort closes and removes the file.
+func (f *atomicFile) Abort() {
+	f.Close()
+	os.Remove(f.File.Name())
+}
+
+// writeIfChanged writes data to the named file unless the file already holds
+// it, so that the modification times of unchanged outputs are kept. It
+// reports whether the file was written.
+func writeIfChanged(name string, data []byte) (bool, error) {
+	if old, err := os.ReadFile(name); err == nil && bytes.Equal(old, data) {
+		return false, nil
+	}
+	f, err := createAtomic(name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return false, err
+	}
+	return true, f.Commit()
+}
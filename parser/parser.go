@@ -0,0 +1,459 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	. "github.com/jinpei0908/rewriting-JackCompiler/jacktokenizer"
+)
+
+// Parser builds an ast.Class from the tokens of one Jack file.
+type Parser struct {
+	tk       *Tokenizer
+	filename string
+	diags    diagnostic.List
+}
+
+// bailout is panicked with by errorAt after the diagnostic has been recorded.
+// It unwinds the recursive descent up to the nearest statement or class
+// member, where parsing resumes after skipping the broken tokens.
+type bailout struct{}
+
+// Tokens at which the parser resynchronizes after a syntax error.
+var statementKeywords = []string{"let", "if", "while", "do", "return"}
+var memberKeywords = []string{"static", "field", "constructor", "function", "method"}
+
+func NewParser(tokenizer *Tokenizer, filename string) *Parser {
+	return &Parser{
+		tk:       tokenizer,
+		filename: filename}
+}
+
+// ParseClass parses the whole input. Errors do not stop parsing of the
+// remaining statements and subroutines; everything found is returned together
+// as a diagnostic.List along with the part of the tree that could be built.
+// The class is nil only if its header could not be parsed.
+func (p *Parser) ParseClass() (class *ast.Class, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+		}
+		err = p.diags.Err()
+	}()
+
+	pos := p.expectKeyword("class")
+	class = &ast.Class{
+		Pos:          pos,
+		Name:         p.expectIdentifier(),
+		ClassVarDecs: []*ast.ClassVarDec{},
+		Subroutines:  []*ast.Subroutine{}}
+	p.expectSymbol("{")
+
+	for {
+		if next := p.tk.CheckNextToken(); next != "static" && next != "field" {
+			break
+		}
+		p.recoverAt(memberKeywords, func() {
+			class.ClassVarDecs = append(class.ClassVarDecs, p.parseClassVarDec())
+		})
+	}
+	for {
+		next := p.tk.CheckNextToken()
+		if next != "constructor" && next != "method" && next != "function" {
+			if len(p.diags) == 0 || next == "" {
+				break
+			}
+			// Leftovers of a subroutine broken by an earlier error.
+			p.recoverAt(memberKeywords, func() {
+				p.tk.Advance()
+				panic(bailout{})
+			})
+			continue
+		}
+		p.recoverAt(memberKeywords, func() {
+			class.Subroutines = append(class.Subroutines, p.parseSubroutine())
+		})
+	}
+	if len(p.diags) == 0 || p.tk.HasMoreTokens() {
+		p.expectSymbol("}")
+	}
+	if p.tk.HasMoreTokens() {
+		p.errorAt(p.tk.PeekToken(), diagnostic.CodeSyntax, "expected end of file, found %q", p.tk.CheckNextToken())
+	}
+	return class, nil
+}
+
+func (p *Parser) parseClassVarDec() *ast.ClassVarDec {
+	kind := p.tk.CheckNextToken()
+	dec := &ast.ClassVarDec{
+		Pos:  p.expectKeyword(kind), // ("static" | "field")
+		Kind: kind,
+		Type: p.parseType()}
+	dec.Names = p.parseNames()
+	return dec
+}
+
+func (p *Parser) parseSubroutine() *ast.Subroutine {
+	kind := p.tk.CheckNextToken()
+	sub := &ast.Subroutine{
+		Pos:        p.expectKeyword(kind), // ("constructor" | "function" | "method")
+		Kind:       kind,
+		ReturnType: p.parseReturnType(),
+		Name:       p.expectIdentifier()}
+
+	p.expectSymbol("(")
+	sub.Params = p.parseParameterList()
+	p.expectSymbol(")")
+
+	p.expectSymbol("{")
+	sub.VarDecs = []*ast.VarDec{}
+	for p.tk.CheckNextToken() == "var" {
+		sub.VarDecs = append(sub.VarDecs, p.parseVarDec())
+	}
+	sub.Statements = p.parseStatements()
+	p.expectSymbol("}")
+	return sub
+}
+
+func (p *Parser) parseParameterList() []*ast.Param {
+	params := []*ast.Param{}
+	if p.tk.CheckNextToken() == ")" {
+		return params
+	}
+
+	for {
+		pos := p.tk.PeekToken()
+		params = append(params, &ast.Param{
+			Pos:  position(pos),
+			Type: p.parseType(),
+			Name: p.expectIdentifier()})
+		if p.tk.CheckNextToken() != "," {
+			return params
+		}
+		p.expectSymbol(",")
+	}
+}
+
+func (p *Parser) parseVarDec() *ast.VarDec {
+	dec := &ast.VarDec{
+		Pos:  p.expectKeyword("var"),
+		Type: p.parseType()}
+	dec.Names = p.parseNames()
+	return dec
+}
+
+// parseNames parses "varName (',' varName)* ';'".
+func (p *Parser) parseNames() []*ast.Ident {
+	names := []*ast.Ident{p.expectIdentifier()}
+	for p.tk.CheckNextToken() != ";" {
+		p.expectSymbol(",")
+		names = append(names, p.expectIdentifier())
+	}
+	p.expectSymbol(";")
+	return names
+}
+
+func (p *Parser) parseStatements() []ast.Statement {
+	statements := []ast.Statement{}
+	for {
+		var parse func() ast.Statement
+		switch p.tk.CheckNextToken() {
+		case "let":
+			parse = p.parseLet
+		case "if":
+			parse = p.parseIf
+		case "while":
+			parse = p.parseWhile
+		case "do":
+			parse = p.parseDo
+		case "return":
+			parse = p.parseReturn
+		case "}", "":
+			return statements
+		default:
+			parse = func() ast.Statement {
+				p.errorAt(p.tk.PeekToken(), diagnostic.CodeSyntax, "expected a statement, found %q", p.tk.CheckNextToken())
+				return nil
+			}
+		}
+		p.recoverAt(statementKeywords, func() {
+			statements = append(statements, parse())
+		})
+	}
+}
+
+func (p *Parser) parseLet() ast.Statement {
+	let := &ast.LetStatement{
+		Pos:  p.expectKeyword("let"),
+		Name: p.expectIdentifier()}
+
+	// If varName is array
+	if p.tk.CheckNextToken() == "[" {
+		p.expectSymbol("[")
+		let.Index = p.parseExpression()
+		p.expectSymbol("]")
+	}
+	p.expectSymbol("=")
+	let.Value = p.parseExpression()
+	p.expectSymbol(";")
+	return let
+}
+
+func (p *Parser) parseIf() ast.Statement {
+	statement := &ast.IfStatement{Pos: p.expectKeyword("if")}
+	p.expectSymbol("(")
+	statement.Cond = p.parseExpression()
+	p.expectSymbol(")")
+	p.expectSymbol("{")
+	statement.Then = p.parseStatements()
+	p.expectSymbol("}")
+
+	if p.tk.CheckNextToken() != "else" {
+		return statement
+	}
+	p.expectKeyword("else")
+	p.expectSymbol("{")
+	statement.Else = p.parseStatements()
+	p.expectSymbol("}")
+	return statement
+}
+
+func (p *Parser) parseWhile() ast.Statement {
+	statement := &ast.WhileStatement{Pos: p.expectKeyword("while")}
+	p.expectSymbol("(")
+	statement.Cond = p.parseExpression()
+	p.expectSymbol(")")
+	p.expectSymbol("{")
+	statement.Body = p.parseStatements()
+	p.expectSymbol("}")
+	return statement
+}
+
+func (p *Parser) parseDo() ast.Statement {
+	statement := &ast.DoStatement{Pos: p.expectKeyword("do")}
+	name := p.expectIdentifier()
+	statement.Call = p.parseSubroutineCall(name)
+	p.expectSymbol(";")
+	return statement
+}
+
+func (p *Parser) parseReturn() ast.Statement {
+	statement := &ast.ReturnStatement{Pos: p.expectKeyword("return")}
+	if p.tk.CheckNextToken() != ";" {
+		statement.Value = p.parseExpression()
+	}
+	p.expectSymbol(";")
+	return statement
+}
+
+func (p *Parser) parseExpression() ast.Expression {
+	expr := p.parseTerm()
+	for {
+		switch op := p.tk.CheckNextToken(); op {
+		case "+", "-", "*", "/", "&", "|", "<", ">", "=":
+			pos := p.expectSymbol(op)
+			expr = &ast.BinaryExpr{
+				Pos:   pos,
+				Op:    op,
+				Left:  expr,
+				Right: p.parseTerm()}
+		default:
+			return expr
+		}
+	}
+}
+
+func (p *Parser) parseExpressionList() []ast.Expression {
+	exprs := []ast.Expression{}
+	if p.tk.CheckNextToken() == ")" {
+		return exprs
+	}
+
+	exprs = append(exprs, p.parseExpression())
+	for p.tk.CheckNextToken() == "," {
+		p.expectSymbol(",")
+		exprs = append(exprs, p.parseExpression())
+	}
+	return exprs
+}
+
+func (p *Parser) parseTerm() ast.Expression {
+	p.advanceIf("an expression", isTermStart)
+	tok := p.tk.CurrentToken()
+	pos := position(tok)
+
+	switch tok.Kind {
+	case IntConst:
+		return &ast.IntegerConstant{Pos: pos, Value: p.tk.IntVal()}
+	case StringConst:
+		return &ast.StringConstant{Pos: pos, Value: p.tk.StringVal()}
+	case Keyword:
+		return &ast.KeywordConstant{Pos: pos, Keyword: tok.Text}
+	case Symbol:
+		if tok.Text == "(" {
+			paren := &ast.ParenExpr{Pos: pos, X: p.parseExpression()}
+			p.expectSymbol(")")
+			return paren
+		}
+		// "-" | "~"
+		return &ast.UnaryExpr{Pos: pos, Op: tok.Text, Operand: p.parseTerm()}
+	}
+
+	// Identifier
+	name := &ast.Ident{Pos: pos, Name: tok.Text}
+	switch p.tk.CheckNextToken() {
+	case "[":
+		p.expectSymbol("[")
+		index := &ast.IndexExpr{Pos: pos, Name: name.Name, Index: p.parseExpression()}
+		p.expectSymbol("]")
+		return index
+	case "(", ".":
+		return p.parseSubroutineCall(name)
+	default:
+		return &ast.VarExpr{Pos: pos, Name: name.Name}
+	}
+}
+
+// parseSubroutineCall parses the rest of a subroutine call whose first
+// identifier has already been consumed.
+func (p *Parser) parseSubroutineCall(first *ast.Ident) *ast.CallExpr {
+	call := &ast.CallExpr{Pos: first.Pos, Name: first.Name}
+	if p.tk.CheckNextToken() == "." {
+		p.expectSymbol(".")
+		call.Receiver = first.Name
+		call.Name = p.expectIdentifier().Name
+	}
+	p.expectSymbol("(")
+	call.Args = p.parseExpressionList()
+	p.expectSymbol(")")
+	return call
+}
+
+// parseType parses "'int' | 'char' | 'boolean' | className".
+func (p *Parser) parseType() string {
+	p.advanceIf("a type", isType)
+	return p.tk.GetCurrentToken()
+}
+
+// parseReturnType parses "'void' | type".
+func (p *Parser) parseReturnType() string {
+	p.advanceIf("a type", func(next Token) bool {
+		return isType(next) || next.Kind == Keyword && next.Text == "void"
+	})
+	return p.tk.GetCurrentToken()
+}
+
+func isType(tok Token) bool {
+	switch tok.Kind {
+	case Keyword:
+		return tok.Text == "int" || tok.Text == "char" || tok.Text == "boolean"
+	case Identifier:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) expectSymbol(expected string) ast.Pos {
+	p.advanceIf(fmt.Sprintf("%q", expected), func(next Token) bool {
+		return next.Kind == Symbol && next.Text == expected
+	})
+	return position(p.tk.CurrentToken())
+}
+
+func (p *Parser) expectKeyword(expected string) ast.Pos {
+	p.advanceIf(fmt.Sprintf("%q", expected), func(next Token) bool {
+		return next.Kind == Keyword && next.Text == expected
+	})
+	return position(p.tk.CurrentToken())
+}
+
+func (p *Parser) expectIdentifier() *ast.Ident {
+	p.advanceIf("an identifier", func(next Token) bool {
+		return next.Kind == Identifier
+	})
+	tok := p.tk.CurrentToken()
+	return &ast.Ident{Pos: position(tok), Name: tok.Text}
+}
+
+// advanceIf moves to the next token if accept allows it. Otherwise the next
+// token is reported and left unconsumed, so that recovery can resynchronize
+// on it.
+func (p *Parser) advanceIf(expected string, accept func(Token) bool) {
+	next := p.tk.PeekToken()
+	if !p.tk.HasMoreTokens() {
+		p.errorAt(next, diagnostic.CodeSyntax, "expected %v, found end of file", expected)
+	}
+	if !accept(next) {
+		p.errorAt(next, diagnostic.CodeSyntax, "expected %v, found %q", expected, p.tk.CheckNextToken())
+	}
+	p.tk.Advance()
+}
+
+// errorAt records a diagnostic located at tok and abandons the construct
+// being parsed.
+func (p *Parser) errorAt(tok Token, code, format string, args ...interface{}) {
+	p.diags.Add(diagnostic.Errorf(p.filename, tok.Line, tok.Column, code, format, args...))
+	panic(bailout{})
+}
+
+// recoverAt runs parse and, if it bails out, skips tokens until the next ";"
+// (which is consumed), "}" or one of the keywords in syncAt. Blocks opened
+// while skipping are skipped as a whole so that braces stay balanced.
+func (p *Parser) recoverAt(syncAt []string, parse func()) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if _, ok := r.(bailout); !ok {
+			panic(r)
+		}
+		depth := 0
+		for p.tk.HasMoreTokens() {
+			next := p.tk.CheckNextToken()
+			if depth == 0 && (next == "}" || contains(syncAt, next)) {
+				return
+			}
+			p.tk.Advance()
+			switch next {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			case ";":
+				if depth == 0 {
+					return
+				}
+			}
+		}
+	}()
+	parse()
+}
+
+func isTermStart(tok Token) bool {
+	switch tok.Kind {
+	case IntConst, StringConst, Identifier:
+		return true
+	case Keyword:
+		return contains([]string{"true", "false", "null", "this"}, tok.Text)
+	case Symbol:
+		return contains([]string{"(", "-", "~"}, tok.Text)
+	}
+	return false
+}
+
+func position(tok Token) ast.Pos {
+	return ast.Pos{Line: tok.Line, Column: tok.Column, Offset: tok.Offset}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
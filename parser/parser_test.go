@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	. "github.com/jinpei0908/rewriting-JackCompiler/jacktokenizer"
+)
+
+func parseString(t *testing.T, src string) (*ast.Class, error) {
+	tk, err := NewTokenizer(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewParser(tk, "Main.jack").ParseClass()
+}
+
+func TestParseClass(t *testing.T) {
+	class, err := parseString(t, `class Main {
+  static int count;
+  method int f(int a, Array b) {
+    var int x;
+    let b[a] = 1 + 2 * -x;
+    if (~(x = 0)) { do g(); } else { do Output.printInt(x); }
+    return x;
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if class.Name.Name != "Main" || len(class.ClassVarDecs) != 1 || len(class.Subroutines) != 1 {
+		t.Fatalf("unexpected class: %+v", class)
+	}
+	sub := class.Subroutines[0]
+	if sub.Kind != "method" || sub.ReturnType != "int" || len(sub.Params) != 2 || len(sub.Statements) != 3 {
+		t.Fatalf("unexpected subroutine: %+v", sub)
+	}
+	if sub.Params[1].Type != "Array" || sub.Params[1].Name.Name != "b" {
+		t.Errorf("unexpected parameter: %+v", sub.Params[1])
+	}
+
+	let := sub.Statements[0].(*ast.LetStatement)
+	if let.Index == nil || let.Name.Pos != (ast.Pos{Line: 5, Column: 9, Offset: 89}) {
+		t.Errorf("unexpected let statement: %+v", let)
+	}
+	// 1 + 2 * -x is ((1 + 2) * (-x))
+	mul, ok := let.Value.(*ast.BinaryExpr)
+	if !ok || mul.Op != "*" {
+		t.Fatalf("expected *, got %#v", let.Value)
+	}
+	if add, ok := mul.Left.(*ast.BinaryExpr); !ok || add.Op != "+" {
+		t.Errorf("expected +, got %#v", mul.Left)
+	}
+	if neg, ok := mul.Right.(*ast.UnaryExpr); !ok || neg.Op != "-" {
+		t.Errorf("expected -, got %#v", mul.Right)
+	}
+
+	ifStatement := sub.Statements[1].(*ast.IfStatement)
+	if len(ifStatement.Then) != 1 || len(ifStatement.Else) != 1 {
+		t.Errorf("unexpected if statement: %+v", ifStatement)
+	}
+	call := ifStatement.Else[0].(*ast.DoStatement).Call
+	if call.Receiver != "Output" || call.Name != "printInt" || len(call.Args) != 1 {
+		t.Errorf("unexpected call: %+v", call)
+	}
+}
+
+func TestParseClassRecoversFromErrors(t *testing.T) {
+	class, err := parseString(t, `class Main {
+  function void main() {
+    let x = ;
+    let y = 1;
+    do f(;
+    return;
+  }
+}
+`)
+	diags, ok := err.(diagnostic.List)
+	if !ok || len(diags) != 2 || diags[0].Line != 3 || diags[1].Line != 5 {
+		t.Fatalf("unexpected diagnostics: %v", err)
+	}
+	// The statements around the broken ones are still in the tree.
+	if statements := class.Subroutines[0].Statements; len(statements) != 2 {
+		t.Errorf("expected 2 statements, got %v", len(statements))
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	for _, c := range []struct {
+		decl   string
+		errors int
+	}{
+		{"var int x;", 0},
+		{"var Array x;", 0},
+		{"var while x;", 1},
+		{"var void x;", 1},
+	} {
+		_, err := parseString(t, "class Main {\n  function void main() {\n    "+c.decl+"\n    return;\n  }\n}\n")
+		diags, _ := err.(diagnostic.List)
+		if len(diags) != c.errors || (err == nil) != (c.errors == 0) {
+			t.Errorf("%v: unexpected diagnostics: %v", c.decl, err)
+		}
+	}
+	_, err := parseString(t, "class Main {\n  function while main() {\n    return;\n  }\n}\n")
+	if diags, ok := err.(diagnostic.List); !ok || len(diags) != 1 || diags[0].Line != 2 {
+		t.Errorf("unexpected diagnostics for a while return type: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compiler"
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/jackos"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmemu"
+)
+
+// run implements "run [flags] <file.jack|dir>", which compiles the program
+// of a directory, or of the directory of a file, in memory and runs it in
+// the VM emulator with the native OS. What the program prints goes to the
+// standard output, and the text of the -input file is typed on the keyboard.
+// It returns the exit status.
+func run(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	var opt optLevel
+	flags.Var(&opt, "O", "optimization `level`: 0 for none, 1 to fold constants and simplify the VM code")
+	typeCheck := flags.Bool("typecheck", false, "check the types of expressions, assignments, calls and returns")
+	steps := flags.Int("steps", 10000000, "stop the program after `n` VM instructions, 0 for no limit")
+	input := flags.String("input", "", "type the text of `file` on the keyboard; the program fails if it reads more keys")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rewriting-JackCompiler run [flags] <file.jack|dir>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "expected one file or directory")
+		flags.Usage()
+		return exitUsage
+	}
+	programs, err := collectPrograms(flags.Args(), sourceFilter{})
+	if err == nil && len(programs) == 0 {
+		err = fmt.Errorf("no jack files in %v", flags.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return inputStatus(err)
+	}
+	var keys []byte
+	if *input != "" {
+		if keys, err = os.ReadFile(*input); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+
+	m, err := loadProgram(programs[0].classes, compiler.Options{TypeCheck: *typeCheck, Optimize: opt > 0}, os.Stderr)
+	if err != nil {
+		if _, ok := err.(diagnostic.List); !ok {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitFailure
+	}
+	jackOS := jackos.New()
+	jackOS.Install(m)
+	jackOS.Type(string(keys))
+	m.MaxSteps = *steps
+
+	err = m.Run()
+	os.Stdout.Write(jackOS.Output.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitRuntime
+	}
+	return exitOK
+}
+
+// loadProgram compiles the classes of a program into a VM emulator. The
+// diagnostics are printed to diags as they are found.
+func loadProgram(jackFileNames []string, opts compiler.Options, diags io.Writer) (*vmemu.Machine, error) {
+	c := compiler.New(opts)
+	for _, file := range jackFileNames {
+		if err := c.AddFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	var failed error
+	m := vmemu.New()
+	for _, file := range jackFileNames {
+		inputFile, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		var vm bytes.Buffer
+		found, err := c.Compile(file, inputFile, &vm, nil)
+		inputFile.Close()
+		for _, d := range found {
+			fmt.Fprintln(diags, d)
+		}
+		if err != nil {
+			failed = err
+			continue
+		}
+		vmName := strings.TrimSuffix(filepath.Base(file), ".jack") + ".vm"
+		if err := m.Load(vmName, &vm); err != nil {
+			return nil, err
+		}
+	}
+	return m, failed
+}
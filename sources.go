@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// patternList is a repeatable flag of file name patterns.
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternList) Set(s string) error {
+	if _, err := filepath.Match(s, ""); err != nil {
+		return fmt.Errorf("invalid pattern %v", s)
+	}
+	*p = append(*p, s)
+	return nil
+}
+
+// sourceFilter selects the Jack files to build among those found in the
+// arguments.
+type sourceFilter struct {
+	recursive bool
+	include   patternList
+	exclude   patternList
+}
+
+// matches reports whether a file or directory matches one of patterns,
+// either by its base name or by its slash-separated path relative to the
+// argument it was found under.
+func matches(patterns patternList, root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// program is the Jack classes of one directory, which are checked against
+// each other.
+type program struct {
+	dir string
+	// root is the argument the program was found under; the outputs mirror
+	// the layout of the sources below it.
+	root string
+	// files are the classes to build.
+	files []string
+	// classes are every class of the directory, built or not.
+	classes []string
+}
+
+// collectPrograms returns the programs of the files and directories in
+// args, sorted by directory. A file argument is built even if the patterns
+// do not select it.
+func collectPrograms(args []string, filter sourceFilter) ([]*program, error) {
+	programs := map[string]*program{}
+	add := func(root, file string) {
+		dir := filepath.Dir(file)
+		p, ok := programs[dir]
+		if !ok {
+			p = &program{dir: dir, root: root}
+			p.classes, _ = filepath.Glob(filepath.Join(dir, "*.jack"))
+			programs[dir] = p
+		}
+		for _, f := range p.files {
+			if f == file {
+				return
+			}
+		}
+		p.files = append(p.files, file)
+	}
+
+	for _, arg := range args {
+		arg = filepath.Clean(arg)
+		fInfo, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fInfo.IsDir() {
+			if filepath.Ext(arg) != ".jack" {
+				return nil, fmt.Errorf("%v is not a jack file", arg)
+			}
+			add(filepath.Dir(arg), arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != arg && (!filter.recursive || strings.HasPrefix(d.Name(), ".") || matches(filter.exclude, arg, path)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".jack" || matches(filter.exclude, arg, path) {
+				return nil
+			}
+			if len(filter.include) > 0 && !matches(filter.include, arg, path) {
+				return nil
+			}
+			add(arg, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sorted []*program
+	for _, p := range programs {
+		sort.Strings(p.files)
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].dir < sorted[j].dir })
+	return sorted, nil
+}
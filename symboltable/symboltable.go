@@ -1,6 +1,9 @@
 package symboltable
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var err error = errors.New("Error occured. ")
 
@@ -46,7 +49,12 @@ func (st *SymbolTable) StartSubroutine(subroutineKind string) {
 	}
 }
 
-func (st *SymbolTable) Define(name, type_, kind string) {
+// Define adds name to the scope selected by kind. Defining a name twice in the
+// same scope is an error; the first definition is kept.
+func (st *SymbolTable) Define(name, type_, kind string) error {
+	if st.isDefinedInScopeOf(name, kind) {
+		return fmt.Errorf("%v is already defined", name)
+	}
 	switch kind {
 	case "static":
 		st.TableOfClassScope[name] = newValue(type_, "Static", st.staticIndex)
@@ -61,6 +69,19 @@ func (st *SymbolTable) Define(name, type_, kind string) {
 		st.TableOfSubroutineScope[name] = newValue(type_, "Argument", st.argIndex)
 		st.argIndex++
 	}
+	return nil
+}
+
+func (st *SymbolTable) isDefinedInScopeOf(name, kind string) bool {
+	switch kind {
+	case "static", "field":
+		_, ok := st.TableOfClassScope[name]
+		return ok
+	case "var", "arg":
+		_, ok := st.TableOfSubroutineScope[name]
+		return ok
+	}
+	return false
 }
 
 func (st *SymbolTable) VarCount(kind string) (int, error) {
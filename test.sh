@@ -2,7 +2,7 @@
 
 function test {
     JackCompiler.sh "$1".jack
-    ./rewriting-JackCompiler "$1".jack
+    ./rewriting-JackCompiler -suffix _ "$1".jack
     d=`diff -bu "$1".vm "$1"_.vm`
 }
 
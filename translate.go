@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmtranslator"
+)
+
+// translate implements "translate [-nobootstrap] <file.vm|dir>", which writes
+// the Hack assembly of a VM file next to it, or of every VM file of a
+// directory into <dir>/<dir>.asm. It returns the exit status.
+func translate(args []string) int {
+	flags := flag.NewFlagSet("translate", flag.ContinueOnError)
+	noBootstrap := flags.Bool("nobootstrap", false, "omit the bootstrap code that calls Sys.init")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rewriting-JackCompiler translate [flags] <file.vm|dir>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "expected one file or directory")
+		flags.Usage()
+		return exitUsage
+	}
+	arg := filepath.Clean(flags.Arg(0))
+
+	fInfo, err := os.Stat(arg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	var vmFileNames []string
+	var asmFileName string
+	if fInfo.IsDir() {
+		vmFileNames, _ = filepath.Glob(filepath.Join(arg, "*.vm"))
+		asmFileName = filepath.Join(arg, filepath.Base(arg)+".asm")
+	} else {
+		if filepath.Ext(arg) != ".vm" {
+			fmt.Fprintf(os.Stderr, "%v is not a vm file\n", arg)
+			return exitUsage
+		}
+		vmFileNames = []string{arg}
+		asmFileName = strings.TrimSuffix(arg, ".vm") + ".asm"
+	}
+
+	if err := translateFiles(vmFileNames, asmFileName, !*noBootstrap); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// translateFiles translates the VM files of a program into one assembly file.
+func translateFiles(vmFileNames []string, asmFileName string, bootstrap bool) error {
+	outputFile, err := createAtomic(asmFileName)
+	if err != nil {
+		return err
+	}
+
+	t := vmtranslator.NewTranslator(outputFile)
+	if bootstrap {
+		t.WriteBootstrap()
+	}
+	for _, file := range vmFileNames {
+		inputFile, err := os.Open(file)
+		if err != nil {
+			outputFile.Abort()
+			return err
+		}
+		err = t.Translate(file, inputFile)
+		inputFile.Close()
+		if err != nil {
+			outputFile.Abort()
+			return err
+		}
+	}
+	if err := t.Flush(); err != nil {
+		outputFile.Abort()
+		return err
+	}
+	return outputFile.Commit()
+}
@@ -0,0 +1,121 @@
+package vmemu
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+type instruction struct {
+	vmwriter.Instruction
+	target   int // resolved address of goto and if-goto
+	file     string
+	line     int
+	function string
+}
+
+// LoadFile loads the VM code of the named file.
+func (m *Machine) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return m.Load(path, file)
+}
+
+// LoadDir loads every .vm file in dir, which is how a Jack program is run.
+func (m *Machine) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.vm"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := m.LoadFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load loads the VM code read from r. The base name of filename scopes the
+// static segment, as the VM translator does. Malformed instructions are
+// returned as a diagnostic.List.
+func (m *Machine) Load(filename string, r io.Reader) error {
+	var code []instruction
+	labels := map[string]int{}
+	functions := map[string]int{}
+	start := len(m.code)
+	function := ""
+	maxStatic := -1
+
+	instructions, lines, err := vmwriter.ParseLines(filename, r)
+	diags, ok := err.(diagnostic.List)
+	if err != nil && !ok {
+		return err
+	}
+	for i, parsed := range instructions {
+		in := instruction{Instruction: parsed, file: filename, line: lines[i]}
+		errorf := func(format string, args ...interface{}) {
+			diags.Add(diagnostic.Errorf(filename, in.line, 1, diagnostic.CodeSyntax, format, args...))
+		}
+
+		switch in.Op {
+		case vmwriter.Push, vmwriter.Pop:
+			if in.Segment == vmwriter.Static && in.Index > maxStatic {
+				maxStatic = in.Index
+			}
+		case vmwriter.Function:
+			function = in.Name
+			_, loaded := m.functions[function]
+			if _, ok := functions[function]; ok || loaded {
+				errorf("function %v is already defined", function)
+				continue
+			}
+			functions[function] = start + len(code)
+		case vmwriter.Label:
+			key := function + "$" + in.Label
+			if _, ok := labels[key]; ok {
+				errorf("label %v is already defined", in.Label)
+				continue
+			}
+			labels[key] = start + len(code)
+		}
+		in.function = function
+		code = append(code, in)
+	}
+
+	for i := range code {
+		in := &code[i]
+		if in.Op != vmwriter.Goto && in.Op != vmwriter.IfGoto {
+			continue
+		}
+		target, ok := labels[in.function+"$"+in.Label]
+		if !ok {
+			diags.Add(diagnostic.Errorf(filename, in.line, 1, diagnostic.CodeUndefined, "undefined label %v", in.Label))
+		}
+		in.target = target
+	}
+
+	base := m.nextStatic
+	if base+maxStatic >= staticEnd {
+		diags.Add(diagnostic.Errorf(filename, 1, 1, diagnostic.CodeInternal, "too many static variables"))
+	}
+	if err := diags.Err(); err != nil {
+		return err
+	}
+	m.nextStatic += maxStatic + 1
+	for name, address := range functions {
+		m.functions[name] = address
+	}
+	for i := range code {
+		if code[i].Segment == vmwriter.Static && (code[i].Op == vmwriter.Push || code[i].Op == vmwriter.Pop) {
+			code[i].Index += base
+		}
+	}
+	m.code = append(m.code, code...)
+	return nil
+}
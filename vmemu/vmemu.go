@@ -0,0 +1,382 @@
+package vmemu
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+// Addresses of the Hack platform used by the VM.
+const (
+	SP         = 0
+	LCL        = 1
+	ARG        = 2
+	THIS       = 3
+	THAT       = 4
+	StackBase  = 256
+	HeapBase   = 2048
+	Screen     = 16384
+	Keyboard   = 24576
+	MemorySize = 32768
+)
+
+const (
+	tempBase    = 5
+	staticBase  = 16
+	staticEnd   = 256
+	frameLength = 5
+	// returnToGo is the return address of functions called by Call.
+	returnToGo = -1
+)
+
+// ErrHalt may be returned by a builtin to stop the program, as Sys.halt does.
+var ErrHalt = errors.New("halt")
+
+// Builtin implements a VM function in Go. It receives the arguments of the
+// call and returns the value pushed in its place.
+type Builtin func(m *Machine, args []int16) (int16, error)
+
+type frame struct {
+	returnAddress int
+	function      string
+}
+
+// Machine executes VM code on the memory of the Hack platform, without any
+// display: the screen and the keyboard are plain memory.
+type Machine struct {
+	RAM [MemorySize]int16
+	// MaxSteps, if not zero, stops programs that run longer with an error.
+	MaxSteps int
+	// Steps counts the VM instructions executed so far.
+	Steps int
+
+	code       []instruction
+	functions  map[string]int
+	builtins   map[string]Builtin
+	nextStatic int
+	frames     []frame
+	pc         int
+}
+
+func New() *Machine {
+	m := &Machine{
+		functions:  map[string]int{},
+		builtins:   map[string]Builtin{},
+		nextStatic: staticBase}
+	m.Reset()
+	return m
+}
+
+// Reset clears the memory and the call stack, keeping the loaded code.
+func (m *Machine) Reset() {
+	m.RAM = [MemorySize]int16{}
+	m.RAM[SP] = StackBase
+	m.RAM[LCL] = StackBase
+	m.RAM[ARG] = StackBase
+	m.frames = nil
+	m.Steps = 0
+}
+
+// Register makes f callable as the VM function name. Functions loaded as VM
+// code take precedence, so that an OS written in Jack replaces builtins.
+func (m *Machine) Register(name string, f Builtin) {
+	m.builtins[name] = f
+}
+
+// Run starts the program as the VM bootstrap code does, by calling Sys.init,
+// or Main.main if there is no Sys.init. It returns when that function
+// returns or the program halts.
+func (m *Machine) Run() error {
+	entry := "Sys.init"
+	if !m.defined(entry) {
+		entry = "Main.main"
+	}
+	_, err := m.Call(entry)
+	if err == ErrHalt {
+		return nil
+	}
+	return err
+}
+
+// Call calls the VM function name with args and runs it until it returns.
+func (m *Machine) Call(name string, args ...int16) (int16, error) {
+	for _, arg := range args {
+		if err := m.push(arg); err != nil {
+			return 0, err
+		}
+	}
+	depth := len(m.frames)
+	if err := m.call(name, len(args), returnToGo); err != nil {
+		return 0, err
+	}
+	for len(m.frames) > depth {
+		if err := m.Step(); err != nil {
+			return 0, err
+		}
+	}
+	return m.pop()
+}
+
+// Step executes one VM instruction.
+func (m *Machine) Step() error {
+	if len(m.frames) == 0 {
+		return errors.New("no function is running")
+	}
+	if m.pc < 0 || m.pc >= len(m.code) {
+		return fmt.Errorf("in %v: execution ran past the end of the code", m.frames[len(m.frames)-1].function)
+	}
+	if m.MaxSteps > 0 && m.Steps >= m.MaxSteps {
+		return fmt.Errorf("in %v: program did not finish in %v steps", m.code[m.pc].function, m.MaxSteps)
+	}
+	m.Steps++
+
+	in := &m.code[m.pc]
+	m.pc++
+	err := m.execute(in)
+	if err != nil && err != ErrHalt {
+		return fmt.Errorf("%v:%v: in %v: %w", in.file, in.line, in.function, err)
+	}
+	return err
+}
+
+func (m *Machine) execute(in *instruction) error {
+	switch in.Op {
+	case vmwriter.Push:
+		value, err := m.read(in.Segment, in.Index)
+		if err != nil {
+			return err
+		}
+		return m.push(value)
+
+	case vmwriter.Pop:
+		value, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.write(in.Segment, in.Index, value)
+
+	case vmwriter.Neg, vmwriter.Not:
+		x, err := m.pop()
+		if err != nil {
+			return err
+		}
+		if in.Op == vmwriter.Neg {
+			return m.push(-x)
+		}
+		return m.push(^x)
+
+	case vmwriter.Add, vmwriter.Sub, vmwriter.Eq, vmwriter.Gt, vmwriter.Lt, vmwriter.And, vmwriter.Or:
+		y, err := m.pop()
+		if err != nil {
+			return err
+		}
+		x, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.push(binary(in.Op, x, y))
+
+	case vmwriter.Label:
+		return nil
+
+	case vmwriter.Goto:
+		m.pc = in.target
+		return nil
+
+	case vmwriter.IfGoto:
+		cond, err := m.pop()
+		if err != nil {
+			return err
+		}
+		if cond != 0 {
+			m.pc = in.target
+		}
+		return nil
+
+	case vmwriter.Function:
+		for i := 0; i < in.NArgs; i++ {
+			if err := m.push(0); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case vmwriter.Call:
+		return m.call(in.Name, in.NArgs, m.pc)
+
+	case vmwriter.Return:
+		return m.ret()
+	}
+	return fmt.Errorf("unknown command %v", in.Op)
+}
+
+func binary(op vmwriter.Opcode, x, y int16) int16 {
+	switch op {
+	case vmwriter.Add:
+		return x + y
+	case vmwriter.Sub:
+		return x - y
+	case vmwriter.And:
+		return x & y
+	case vmwriter.Or:
+		return x | y
+	case vmwriter.Eq:
+		return boolean(x == y)
+	case vmwriter.Gt:
+		return boolean(x > y)
+	default: // vmwriter.Lt
+		return boolean(x < y)
+	}
+}
+
+func boolean(b bool) int16 {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+func (m *Machine) defined(name string) bool {
+	_, ok := m.functions[name]
+	_, builtin := m.builtins[name]
+	return ok || builtin
+}
+
+// call calls name with the nArgs values on top of the stack. Return addresses
+// are kept outside the RAM, which only holds a copy, so that programs larger
+// than the address space of the Hack platform can still be run.
+func (m *Machine) call(name string, nArgs int, returnAddress int) error {
+	if int(m.RAM[SP])-nArgs < StackBase {
+		return errors.New("stack underflow")
+	}
+	start, ok := m.functions[name]
+	if !ok {
+		builtin, ok := m.builtins[name]
+		if !ok {
+			return fmt.Errorf("undefined function %v", name)
+		}
+		return m.callBuiltin(name, builtin, nArgs, returnAddress)
+	}
+
+	for _, value := range []int16{int16(returnAddress), m.RAM[LCL], m.RAM[ARG], m.RAM[THIS], m.RAM[THAT]} {
+		if err := m.push(value); err != nil {
+			return err
+		}
+	}
+	m.RAM[ARG] = m.RAM[SP] - int16(nArgs) - frameLength
+	m.RAM[LCL] = m.RAM[SP]
+	m.frames = append(m.frames, frame{returnAddress: returnAddress, function: name})
+	m.pc = start
+	return nil
+}
+
+func (m *Machine) callBuiltin(name string, builtin Builtin, nArgs int, returnAddress int) error {
+	sp := int(m.RAM[SP]) - nArgs
+	args := make([]int16, nArgs)
+	copy(args, m.RAM[sp:])
+	m.RAM[SP] = int16(sp)
+
+	// The frame lets a builtin call back into VM code with Call, as Sys.init
+	// calls Main.main.
+	m.frames = append(m.frames, frame{returnAddress: returnAddress, function: name})
+	value, err := builtin(m, args)
+	m.frames = m.frames[:len(m.frames)-1]
+	if err != nil {
+		if err == ErrHalt {
+			return err
+		}
+		return fmt.Errorf("%v: %w", name, err)
+	}
+	if err := m.push(value); err != nil {
+		return err
+	}
+	m.pc = returnAddress
+	return nil
+}
+
+func (m *Machine) ret() error {
+	value, err := m.pop()
+	if err != nil {
+		return err
+	}
+	frameAddress := int(m.RAM[LCL])
+	arg := int(m.RAM[ARG])
+	if len(m.frames) == 0 || frameAddress-frameLength < StackBase || arg < StackBase || arg >= HeapBase {
+		return errors.New("return without a call frame")
+	}
+	m.RAM[arg] = value
+	m.RAM[SP] = m.RAM[ARG] + 1
+	m.RAM[THAT] = m.RAM[frameAddress-1]
+	m.RAM[THIS] = m.RAM[frameAddress-2]
+	m.RAM[ARG] = m.RAM[frameAddress-3]
+	m.RAM[LCL] = m.RAM[frameAddress-4]
+
+	top := m.frames[len(m.frames)-1]
+	m.frames = m.frames[:len(m.frames)-1]
+	m.pc = top.returnAddress
+	return nil
+}
+
+func (m *Machine) push(value int16) error {
+	sp := int(m.RAM[SP])
+	if sp < StackBase || sp >= HeapBase {
+		return errors.New("stack overflow")
+	}
+	m.RAM[sp] = value
+	m.RAM[SP]++
+	return nil
+}
+
+func (m *Machine) pop() (int16, error) {
+	sp := int(m.RAM[SP]) - 1
+	if sp < StackBase {
+		return 0, errors.New("stack underflow")
+	}
+	m.RAM[SP]--
+	return m.RAM[sp], nil
+}
+
+func (m *Machine) read(segment vmwriter.Segment, index int) (int16, error) {
+	if segment == vmwriter.Constant {
+		return int16(index), nil
+	}
+	address, err := m.address(segment, index)
+	if err != nil {
+		return 0, err
+	}
+	return m.RAM[address], nil
+}
+
+func (m *Machine) write(segment vmwriter.Segment, index int, value int16) error {
+	address, err := m.address(segment, index)
+	if err != nil {
+		return err
+	}
+	m.RAM[address] = value
+	return nil
+}
+
+func (m *Machine) address(segment vmwriter.Segment, index int) (int, error) {
+	var address int
+	switch segment {
+	case vmwriter.Local:
+		address = int(m.RAM[LCL]) + index
+	case vmwriter.Argument:
+		address = int(m.RAM[ARG]) + index
+	case vmwriter.This:
+		address = int(m.RAM[THIS]) + index
+	case vmwriter.That:
+		address = int(m.RAM[THAT]) + index
+	case vmwriter.Pointer:
+		address = THIS + index
+	case vmwriter.Temp:
+		address = tempBase + index
+	default: // vmwriter.Static, already relocated by Load
+		address = index
+	}
+	if address < 0 || address >= MemorySize {
+		return 0, fmt.Errorf("address %v of %v %v is out of memory", address, segment, index)
+	}
+	return address, nil
+}
@@ -0,0 +1,112 @@
+package vmemu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/compilationengine"
+)
+
+func TestCallAndReturn(t *testing.T) {
+	m := New()
+	err := m.Load("Fib.vm", strings.NewReader(`// fib(n) computed recursively
+function Fib.fib 0
+push argument 0
+push constant 2
+lt
+if-goto BASE
+push argument 0
+push constant 1
+sub
+call Fib.fib 1
+push argument 0
+push constant 2
+sub
+call Fib.fib 1
+add
+return
+label BASE
+push argument 0
+return
+function Fib.count 1
+push static 0
+push constant 1
+add
+pop static 0
+push static 0
+return
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := m.Call("Fib.fib", 10); err != nil || value != 55 {
+		t.Errorf("Fib.fib(10) = %v, %v; expect 55", value, err)
+	}
+	m.Call("Fib.count")
+	if value, _ := m.Call("Fib.count"); value != 2 {
+		t.Errorf("Fib.count() = %v; expect 2", value)
+	}
+	if sp := m.RAM[SP]; sp != StackBase {
+		t.Errorf("stack is not empty after the calls: SP = %v", sp)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	err := New().Load("Bad.vm", strings.NewReader(`function Bad.f 0
+pop constant 1
+push temp 8
+goto NOWHERE
+jump
+`))
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	if lines := strings.Count(err.Error(), "\n") + 1; lines != 4 {
+		t.Errorf("expected 4 errors, got:\n%v", err)
+	}
+}
+
+func TestRunCompiledProgram(t *testing.T) {
+	dir := t.TempDir()
+	inputFile, err := os.Open("../testcases/Seven/Main.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inputFile.Close()
+	outputFile, err := os.Create(filepath.Join(dir, "Main.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugFile, err := os.Create(filepath.Join(dir, "Main.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer debugFile.Close()
+	if err := compilationengine.NewCompilationEngine(inputFile, outputFile, debugFile).CompileClass(); err != nil {
+		t.Fatal(err)
+	}
+	outputFile.Close()
+
+	m := New()
+	if err := m.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	printed := []int16{}
+	m.Register("Math.multiply", func(m *Machine, args []int16) (int16, error) {
+		return args[0] * args[1], nil
+	})
+	m.Register("Output.printInt", func(m *Machine, args []int16) (int16, error) {
+		printed = append(printed, args[0])
+		return 0, nil
+	})
+	m.MaxSteps = 1000
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) != 1 || printed[0] != 7 {
+		t.Errorf("printed %v; expect [7]", printed)
+	}
+}
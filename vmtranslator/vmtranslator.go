@@ -0,0 +1,257 @@
+package vmtranslator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/vmwriter"
+)
+
+// Translator translates VM code into Hack assembly. The files of a program
+// are translated one after the other into the same output, following the
+// standard mapping of the VM on the Hack platform: SP, LCL, ARG, THIS and
+// THAT in RAM[0..4], temp in RAM[5..12], R13 and R14 as scratch registers
+// and statics as symbols named after their file.
+type Translator struct {
+	w         *bufio.Writer
+	err       error
+	className string // the static prefix of the current file
+	function  string
+	nLabels   int
+}
+
+var segmentPointers = map[vmwriter.Segment]string{
+	vmwriter.Local:    "LCL",
+	vmwriter.Argument: "ARG",
+	vmwriter.This:     "THIS",
+	vmwriter.That:     "THAT",
+}
+
+var jumps = map[vmwriter.Opcode]string{
+	vmwriter.Eq: "JEQ",
+	vmwriter.Gt: "JGT",
+	vmwriter.Lt: "JLT",
+}
+
+var binaryOperators = map[vmwriter.Opcode]string{
+	vmwriter.Add: "M=D+M",
+	vmwriter.Sub: "M=M-D",
+	vmwriter.And: "M=D&M",
+	vmwriter.Or:  "M=D|M",
+}
+
+func NewTranslator(w io.Writer) *Translator {
+	return &Translator{w: bufio.NewWriter(w)}
+}
+
+// WriteBootstrap writes the code that starts a program: it sets up the stack
+// and calls Sys.init.
+func (t *Translator) WriteBootstrap() {
+	t.emit("// bootstrap")
+	t.emit("@256", "D=A", "@SP", "M=D")
+	t.function = "Bootstrap"
+	t.writeCall("Sys.init", 0)
+}
+
+// Translate translates the VM code read from r. The base name of filename
+// prefixes the static variables of the file. Malformed commands are returned
+// as a diagnostic.List, and nothing is written for a file containing one.
+func (t *Translator) Translate(filename string, r io.Reader) error {
+	code, lines, err := vmwriter.ParseLines(filename, r)
+	diags, ok := err.(diagnostic.List)
+	if err != nil && !ok {
+		return err
+	}
+	diags = append(diags, checkLabels(filename, code, lines)...)
+	if err := diags.Err(); err != nil {
+		return err
+	}
+	t.className = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	for _, in := range code {
+		t.emit("// " + in.String())
+		t.writeInstruction(in)
+	}
+	return t.err
+}
+
+// Flush writes any buffered output and returns the first write error.
+func (t *Translator) Flush() error {
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+func (t *Translator) writeInstruction(in vmwriter.Instruction) {
+	switch in.Op {
+	case vmwriter.Push:
+		t.writePush(in.Segment, in.Index)
+	case vmwriter.Pop:
+		t.writePop(in.Segment, in.Index)
+	case vmwriter.Neg:
+		t.emit("@SP", "A=M-1", "M=-M")
+	case vmwriter.Not:
+		t.emit("@SP", "A=M-1", "M=!M")
+	case vmwriter.Add, vmwriter.Sub, vmwriter.And, vmwriter.Or:
+		t.emit("@SP", "AM=M-1", "D=M", "A=A-1", binaryOperators[in.Op])
+	case vmwriter.Eq, vmwriter.Gt, vmwriter.Lt:
+		t.writeComparison(jumps[in.Op])
+	case vmwriter.Label:
+		t.emit(fmt.Sprintf("(%v$%v)", t.function, in.Label))
+	case vmwriter.Goto:
+		t.emit(fmt.Sprintf("@%v$%v", t.function, in.Label), "0;JMP")
+	case vmwriter.IfGoto:
+		t.emit("@SP", "AM=M-1", "D=M", fmt.Sprintf("@%v$%v", t.function, in.Label), "D;JNE")
+	case vmwriter.Function:
+		t.writeFunction(in.Name, in.NArgs)
+	case vmwriter.Call:
+		t.writeCall(in.Name, in.NArgs)
+	case vmwriter.Return:
+		t.writeReturn()
+	}
+}
+
+func (t *Translator) writePush(segment vmwriter.Segment, index int) {
+	switch segment {
+	case vmwriter.Constant:
+		t.emit(fmt.Sprintf("@%v", index), "D=A")
+	case vmwriter.Local, vmwriter.Argument, vmwriter.This, vmwriter.That:
+		t.emit(fmt.Sprintf("@%v", index), "D=A", "@"+segmentPointers[segment], "A=D+M", "D=M")
+	default:
+		t.emit("@"+t.address(segment, index), "D=M")
+	}
+	t.emit("@SP", "A=M", "M=D", "@SP", "M=M+1")
+}
+
+func (t *Translator) writePop(segment vmwriter.Segment, index int) {
+	switch segment {
+	case vmwriter.Local, vmwriter.Argument, vmwriter.This, vmwriter.That:
+		t.emit(fmt.Sprintf("@%v", index), "D=A", "@"+segmentPointers[segment], "D=D+M", "@R13", "M=D")
+		t.emit("@SP", "AM=M-1", "D=M", "@R13", "A=M", "M=D")
+	default:
+		t.emit("@SP", "AM=M-1", "D=M", "@"+t.address(segment, index), "M=D")
+	}
+}
+
+// address returns the symbol of a word of the segments at fixed addresses.
+func (t *Translator) address(segment vmwriter.Segment, index int) string {
+	switch segment {
+	case vmwriter.Pointer:
+		if index == 0 {
+			return "THIS"
+		}
+		return "THAT"
+	case vmwriter.Temp:
+		return fmt.Sprintf("R%v", 5+index)
+	default: // vmwriter.Static
+		return fmt.Sprintf("%v.%v", t.className, index)
+	}
+}
+
+// writeComparison replaces the two operands by true (-1) if x jump y holds,
+// and by false (0) otherwise. x-y overflows when x and y have opposite signs,
+// as in 20000 > -20000, so gt and lt only subtract operands of the same sign;
+// otherwise the sign of x decides. eq is not affected by the overflow.
+func (t *Translator) writeComparison(jump string) {
+	label := t.newLabel("cmp")
+	if jump != "JEQ" {
+		// When x >= 0 > y, x > y; when x < 0 <= y, x < y.
+		positive, negative := "-1", "0"
+		if jump == "JLT" {
+			positive, negative = "0", "-1"
+		}
+		t.emit("@SP", "A=M-1", "D=M", "@R13", "M=D", "@SP", "A=M-1", "A=A-1", "D=M")
+		t.emit("@"+label+".xneg", "D;JLT")
+		t.emit("@R13", "D=M", "@"+label+".sub", "D;JGE", "D="+positive, "@"+label+".set", "0;JMP")
+		t.emit("("+label+".xneg)", "@R13", "D=M", "@"+label+".sub", "D;JLT", "D="+negative)
+		t.emit("("+label+".set)", "@SP", "AM=M-1", "A=A-1", "M=D", "@"+label, "0;JMP")
+		t.emit("(" + label + ".sub)")
+	}
+	t.emit("@SP", "AM=M-1", "D=M", "A=A-1", "D=M-D", "M=-1")
+	t.emit("@"+label, "D;"+jump)
+	t.emit("@SP", "A=M-1", "M=0")
+	t.emit("(" + label + ")")
+}
+
+func (t *Translator) writeFunction(name string, nLocals int) {
+	t.function = name
+	t.emit("(" + name + ")")
+	for i := 0; i < nLocals; i++ {
+		t.emit("@SP", "A=M", "M=0", "@SP", "M=M+1")
+	}
+}
+
+func (t *Translator) writeCall(name string, nArgs int) {
+	returnLabel := t.newLabel("ret")
+	t.emit("@"+returnLabel, "D=A", "@SP", "A=M", "M=D", "@SP", "M=M+1")
+	for _, pointer := range []string{"LCL", "ARG", "THIS", "THAT"} {
+		t.emit("@"+pointer, "D=M", "@SP", "A=M", "M=D", "@SP", "M=M+1")
+	}
+	// ARG = SP - nArgs - 5, LCL = SP
+	t.emit("@SP", "D=M", fmt.Sprintf("@%v", nArgs+5), "D=D-A", "@ARG", "M=D")
+	t.emit("@SP", "D=M", "@LCL", "M=D")
+	t.emit("@"+name, "0;JMP")
+	t.emit("(" + returnLabel + ")")
+}
+
+func (t *Translator) writeReturn() {
+	// R13 = frame, R14 = return address
+	t.emit("@LCL", "D=M", "@R13", "M=D")
+	t.emit("@5", "A=D-A", "D=M", "@R14", "M=D")
+	// *ARG = pop(), SP = ARG + 1
+	t.emit("@SP", "AM=M-1", "D=M", "@ARG", "A=M", "M=D")
+	t.emit("@ARG", "D=M+1", "@SP", "M=D")
+	for _, pointer := range []string{"THAT", "THIS", "ARG", "LCL"} {
+		t.emit("@R13", "AM=M-1", "D=M", "@"+pointer, "M=D")
+	}
+	t.emit("@R14", "A=M", "0;JMP")
+}
+
+// newLabel returns a label unique in the whole program. It has two '$',
+// unlike the labels of the VM code, function$label, as VM symbols cannot
+// contain '$'.
+func (t *Translator) newLabel(kind string) string {
+	t.nLabels++
+	return fmt.Sprintf("%v$%v$%v", t.function, kind, t.nLabels)
+}
+
+func (t *Translator) emit(lines ...string) {
+	for _, line := range lines {
+		if t.err != nil {
+			return
+		}
+		_, t.err = t.w.WriteString(line + "\n")
+	}
+}
+
+// checkLabels checks that the jumps of code target a label of their
+// function. lines are the lines of the instructions.
+func checkLabels(filename string, code []vmwriter.Instruction, lines []int) diagnostic.List {
+	var diags diagnostic.List
+	labels := map[string]bool{}
+	function := ""
+	for _, in := range code {
+		switch in.Op {
+		case vmwriter.Function:
+			function = in.Name
+		case vmwriter.Label:
+			labels[function+"$"+in.Label] = true
+		}
+	}
+	function = ""
+	for i, in := range code {
+		switch in.Op {
+		case vmwriter.Function:
+			function = in.Name
+		case vmwriter.Goto, vmwriter.IfGoto:
+			if !labels[function+"$"+in.Label] {
+				diags.Add(diagnostic.Errorf(filename, lines[i], 1, diagnostic.CodeUndefined, "undefined label %v", in.Label))
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,94 @@
+package vmtranslator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func translateString(t *testing.T, src string) (string, error) {
+	var out bytes.Buffer
+	tr := NewTranslator(&out)
+	err := tr.Translate("dir/Foo.vm", strings.NewReader(src))
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), err
+}
+
+func TestTranslate(t *testing.T) {
+	actual, err := translateString(t, "function Foo.bar 0\npush constant 7\npush static 1\nadd\npop temp 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `// function Foo.bar 0
+(Foo.bar)
+// push constant 7
+@7
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+// push static 1
+@Foo.1
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+// add
+@SP
+AM=M-1
+D=M
+A=A-1
+M=D+M
+// pop temp 2
+@SP
+AM=M-1
+D=M
+@R7
+M=D
+`
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestTranslateLabels(t *testing.T) {
+	actual, err := translateString(t, `function Foo.bar 0
+label LOOP
+label cmp.1
+label ret.3
+push constant 1
+push constant 2
+eq
+push constant 1
+push constant 2
+lt
+call Foo.bar 2
+if-goto LOOP
+return
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The labels of the VM code cannot collide with the generated ones.
+	for _, label := range []string{"(Foo.bar$LOOP)", "(Foo.bar$cmp.1)", "(Foo.bar$ret.3)", "(Foo.bar$cmp$1)", "(Foo.bar$cmp$2)", "(Foo.bar$ret$3)", "@Foo.bar$LOOP"} {
+		if strings.Count(actual, label+"\n") != 1 {
+			t.Errorf("%v should appear once in:\n%v", label, actual)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	_, err := translateString(t, "function Foo.bar 0\npop constant 1\npush temp 8\ngoto NOWHERE\njump\n")
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	if lines := strings.Count(err.Error(), "\n") + 1; lines != 4 {
+		t.Errorf("expected 4 errors, got:\n%v", err)
+	}
+}
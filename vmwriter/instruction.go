@@ -0,0 +1,240 @@
+package vmwriter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+// Opcode is the command of a VM instruction.
+type Opcode int
+
+const (
+	Push Opcode = iota
+	Pop
+	Add
+	Sub
+	Neg
+	Eq
+	Gt
+	Lt
+	And
+	Or
+	Not
+	Label
+	Goto
+	IfGoto
+	Function
+	Call
+	Return
+)
+
+var opcodeNames = [...]string{
+	Push:     "push",
+	Pop:      "pop",
+	Add:      "add",
+	Sub:      "sub",
+	Neg:      "neg",
+	Eq:       "eq",
+	Gt:       "gt",
+	Lt:       "lt",
+	And:      "and",
+	Or:       "or",
+	Not:      "not",
+	Label:    "label",
+	Goto:     "goto",
+	IfGoto:   "if-goto",
+	Function: "function",
+	Call:     "call",
+	Return:   "return",
+}
+
+func (op Opcode) String() string {
+	return opcodeNames[op]
+}
+
+// Segment is a memory segment of the VM.
+type Segment int
+
+const (
+	Constant Segment = iota
+	Argument
+	Local
+	Static
+	This
+	That
+	Pointer
+	Temp
+)
+
+var segmentNames = [...]string{
+	Constant: "constant",
+	Argument: "argument",
+	Local:    "local",
+	Static:   "static",
+	This:     "this",
+	That:     "that",
+	Pointer:  "pointer",
+	Temp:     "temp",
+}
+
+func (s Segment) String() string {
+	return segmentNames[s]
+}
+
+// Instruction is one VM command. Only the fields its opcode uses are set:
+// Segment and Index for push and pop, Label for label, goto and if-goto, Name
+// and NArgs for function and call. NArgs of a function is its number of
+// local variables.
+type Instruction struct {
+	Op      Opcode
+	Segment Segment
+	Index   int
+	Label   string
+	Name    string
+	NArgs   int
+}
+
+func (in Instruction) String() string {
+	switch in.Op {
+	case Push, Pop:
+		return fmt.Sprintf("%v %v %v", in.Op, in.Segment, in.Index)
+	case Label, Goto, IfGoto:
+		return fmt.Sprintf("%v %v", in.Op, in.Label)
+	case Function, Call:
+		return fmt.Sprintf("%v %v %v", in.Op, in.Name, in.NArgs)
+	}
+	return in.Op.String()
+}
+
+// Write writes code as VM text, one command per line.
+func Write(w io.Writer, code []Instruction) error {
+	bw := bufio.NewWriter(w)
+	for _, in := range code {
+		bw.WriteString(in.String() + "\n")
+	}
+	return bw.Flush()
+}
+
+// Parse reads VM text. Comments and blank lines are skipped; malformed
+// commands are reported as a diagnostic.List, returned along with the
+// well-formed instructions.
+func Parse(filename string, r io.Reader) ([]Instruction, error) {
+	code, _, err := ParseLines(filename, r)
+	return code, err
+}
+
+// ParseLines is Parse, also returning the line each instruction was read
+// from, for the tools that report errors at run time.
+func ParseLines(filename string, r io.Reader) ([]Instruction, []int, error) {
+	var diags diagnostic.List
+	var code []Instruction
+	var lines []int
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		in, err := parseInstruction(fields)
+		if err != nil {
+			diags.Add(diagnostic.Errorf(filename, line, 1, diagnostic.CodeSyntax, "%v", err))
+			continue
+		}
+		code = append(code, in)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return code, lines, diags.Err()
+}
+
+// maxNumber is the largest operand of a VM command, that of a constant.
+const maxNumber = 32767
+
+func parseInstruction(fields []string) (Instruction, error) {
+	op, ok := lookupName(opcodeNames[:], fields[0])
+	if !ok {
+		return Instruction{}, fmt.Errorf("unknown command %v", fields[0])
+	}
+	in := Instruction{Op: Opcode(op)}
+	operands := 0
+	switch in.Op {
+	case Push, Pop, Function, Call:
+		operands = 2
+	case Label, Goto, IfGoto:
+		operands = 1
+	}
+	if len(fields)-1 != operands {
+		return Instruction{}, fmt.Errorf("%v expects %v operands, found %v", fields[0], operands, len(fields)-1)
+	}
+
+	switch in.Op {
+	case Push, Pop:
+		segment, ok := lookupName(segmentNames[:], fields[1])
+		if !ok {
+			return Instruction{}, fmt.Errorf("unknown segment %v", fields[1])
+		}
+		in.Segment = Segment(segment)
+		n, err := strconv.Atoi(fields[2])
+		if err != nil || n < 0 || n > maxNumber {
+			return Instruction{}, fmt.Errorf("invalid number %v", fields[2])
+		}
+		in.Index = n
+		switch {
+		case in.Op == Pop && in.Segment == Constant:
+			return Instruction{}, fmt.Errorf("cannot pop segment constant")
+		case in.Segment == Pointer && n > 1, in.Segment == Temp && n > 7:
+			return Instruction{}, fmt.Errorf("index %v is out of the %v segment", n, in.Segment)
+		}
+	case Label, Goto, IfGoto:
+		if !isSymbol(fields[1]) {
+			return Instruction{}, fmt.Errorf("invalid label %v", fields[1])
+		}
+		in.Label = fields[1]
+	case Function, Call:
+		if !isSymbol(fields[1]) {
+			return Instruction{}, fmt.Errorf("invalid function name %v", fields[1])
+		}
+		in.Name = fields[1]
+		n, err := strconv.Atoi(fields[2])
+		if err != nil || n < 0 || n > maxNumber {
+			return Instruction{}, fmt.Errorf("invalid number %v", fields[2])
+		}
+		in.NArgs = n
+	}
+	return in, nil
+}
+
+// isSymbol reports whether s is a symbol of the VM language: letters, digits,
+// '_', '.' and ':', not starting with a digit. Symbols cannot contain '$',
+// which the translator keeps for the labels it generates.
+func isSymbol(s string) bool {
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '.', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+func lookupName(names []string, name string) (int, bool) {
+	for i, n := range names {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
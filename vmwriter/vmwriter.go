@@ -2,97 +2,118 @@ package vmwriter
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"io"
 )
 
+// VmWriter collects the instructions emitted by the code generator. Nothing
+// is written to the file until Flush, so that the code can be inspected or
+// transformed first.
 type VmWriter struct {
-	file *os.File
+	w    io.Writer
+	code []Instruction
 }
 
-func NewVmWriter(outputfile *os.File) *VmWriter {
-	return &VmWriter{file: outputfile}
+func NewVmWriter(w io.Writer) *VmWriter {
+	return &VmWriter{w: w}
 }
 
-func (vm *VmWriter) WritePush(segment string, index int) {
-	vm.file.WriteString(
-		fmt.Sprintf("push %v %v\n", segment, index))
+var arithmetic = map[string]Opcode{
+	"+": Add,
+	"~": Not,
+	"=": Eq,
+	"<": Lt,
+	">": Gt,
+	"&": And,
+	"|": Or,
 }
 
-func (vm *VmWriter) WritePop(segment string, index int) {
-	vm.file.WriteString(
-		fmt.Sprintf("pop %v %v\n", segment, index))
+func (vm *VmWriter) WritePush(segment Segment, index int) {
+	vm.emit(Instruction{Op: Push, Segment: segment, Index: index})
 }
 
-func (vm *VmWriter) WriteArithmetic(command string, inTerm bool) {
+func (vm *VmWriter) WritePop(segment Segment, index int) {
+	vm.emit(Instruction{Op: Pop, Segment: segment, Index: index})
+}
+
+func (vm *VmWriter) WriteArithmetic(command string, inTerm bool) error {
 	switch command {
-	case "+":
-		vm.file.WriteString("add\n")
 	case "-":
 		if inTerm {
-			vm.file.WriteString("neg\n")
+			vm.emit(Instruction{Op: Neg})
 		} else {
-			vm.file.WriteString("sub\n")
+			vm.emit(Instruction{Op: Sub})
 		}
 	case "*":
-		vm.file.WriteString("call Math.multiply 2\n")
+		vm.WriteCall("Math.multiply", 2)
 	case "/":
-		vm.file.WriteString("call Math.divide 2\n")
-	case "~":
-		vm.file.WriteString("not\n")
-	case "=":
-		vm.file.WriteString("eq\n")
-	case "<":
-		vm.file.WriteString("lt\n")
-	case ">":
-		vm.file.WriteString("gt\n")
-	case "&":
-		vm.file.WriteString("and\n")
-	case "|":
-		vm.file.WriteString("or\n")
+		vm.WriteCall("Math.divide", 2)
 	default:
-		log.Fatalln("There is no arithmetic command.")
+		op, ok := arithmetic[command]
+		if !ok {
+			return fmt.Errorf("There is no arithmetic command: %q", command)
+		}
+		vm.emit(Instruction{Op: op})
 	}
+	return nil
 }
 
 func (vm *VmWriter) WriteLabel(label string) {
-	vm.file.WriteString(
-		fmt.Sprintf("label %v\n", label))
+	vm.emit(Instruction{Op: Label, Label: label})
 }
 
 func (vm *VmWriter) WriteGoto(label string) {
-	vm.file.WriteString(
-		fmt.Sprintf("goto %v\n", label))
+	vm.emit(Instruction{Op: Goto, Label: label})
 }
 
 func (vm *VmWriter) WriteIf(label string) {
-	vm.file.WriteString(
-		fmt.Sprintf("if-goto %v\n", label))
+	vm.emit(Instruction{Op: IfGoto, Label: label})
 }
 
 func (vm *VmWriter) WriteCall(name string, nArgs int) {
-	vm.file.WriteString(
-		fmt.Sprintf("call %v %v\n", name, nArgs))
+	vm.emit(Instruction{Op: Call, Name: name, NArgs: nArgs})
 }
 
 func (vm *VmWriter) WriteFunction(subroutineKind string, className string, subroutineName string, nLocals int, numberOfStatic int) {
-	vm.file.WriteString(
-		fmt.Sprintf("function %v.%v %v\n", className, subroutineName, nLocals))
+	vm.emit(Instruction{Op: Function, Name: fmt.Sprintf("%v.%v", className, subroutineName), NArgs: nLocals})
 	switch subroutineKind {
 	case "method":
-		vm.file.WriteString("push argument 0\n")
-		vm.file.WriteString("pop pointer 0\n")
+		vm.WritePush(Argument, 0)
+		vm.WritePop(Pointer, 0)
 	case "constructor":
-		vm.file.WriteString(fmt.Sprintf("push constant %v\n", numberOfStatic))
-		vm.file.WriteString("call Memory.alloc 1\n")
-		vm.file.WriteString("pop pointer 0\n")
+		vm.WritePush(Constant, numberOfStatic)
+		vm.WriteCall("Memory.alloc", 1)
+		vm.WritePop(Pointer, 0)
 	}
 }
 
 func (vm *VmWriter) WriteReturn() {
-	vm.file.WriteString("return\n")
+	vm.emit(Instruction{Op: Return})
+}
+
+func (vm *VmWriter) emit(in Instruction) {
+	vm.code = append(vm.code, in)
+}
+
+// Instructions returns the instructions emitted since the last Flush.
+func (vm *VmWriter) Instructions() []Instruction {
+	return vm.code
+}
+
+// SetInstructions replaces the instructions to be written, typically by a
+// transformed version of Instructions.
+func (vm *VmWriter) SetInstructions(code []Instruction) {
+	vm.code = code
+}
+
+// Flush writes the instructions as VM text and empties the list.
+func (vm *VmWriter) Flush() error {
+	err := Write(vm.w, vm.code)
+	vm.code = nil
+	return err
 }
 
 func (vm *VmWriter) Close() {
-	vm.file.Close()
+	if closer, ok := vm.w.(io.Closer); ok {
+		closer.Close()
+	}
 }
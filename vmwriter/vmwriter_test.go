@@ -0,0 +1,50 @@
+package vmwriter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+)
+
+func TestParseAndWrite(t *testing.T) {
+	src := `function Main.main 1
+push constant 7
+pop local 0
+label LOOP
+push local 0
+neg
+if-goto LOOP
+call Output.printInt 1
+pop temp 0
+return
+`
+	code, err := Parse("Main.vm", strings.NewReader("// compiled\n"+src+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code[1] != (Instruction{Op: Push, Segment: Constant, Index: 7}) ||
+		code[3] != (Instruction{Op: Label, Label: "LOOP"}) ||
+		code[7] != (Instruction{Op: Call, Name: "Output.printInt", NArgs: 1}) {
+		t.Errorf("unexpected instructions: %v", code)
+	}
+	var out bytes.Buffer
+	Write(&out, code)
+	if actual := out.String(); actual != src {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, src)
+	}
+}
+
+func TestParseReportsMalformedCommands(t *testing.T) {
+	_, err := Parse("Main.vm", strings.NewReader("push heap 0\npop local\ncall Main.main -1\nmul\npop constant 1\npush temp 8\npush constant 32768\nlabel a$b\ncall 1f 0\n"))
+	diags, ok := err.(diagnostic.List)
+	if !ok || len(diags) != 9 {
+		t.Fatalf("unexpected diagnostics: %v", err)
+	}
+	for i, d := range diags {
+		if d.Line != i+1 || d.Code != diagnostic.CodeSyntax {
+			t.Errorf("unexpected diagnostic: %v", d)
+		}
+	}
+}
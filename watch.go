@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/diagnostic"
+	"github.com/jinpei0908/rewriting-JackCompiler/jackos"
+)
+
+// stamp is what tells that a file changed between two polls.
+type stamp struct {
+	modTime int64
+	size    int64
+}
+
+// watcher remembers the state of the sources at the last poll.
+type watcher struct {
+	// stamps are the stamps of every class, by file.
+	stamps map[string]stamp
+	// dirs are the stamps of the classes of each program, joined, by
+	// directory, so that added and removed classes are noticed.
+	dirs map[string]string
+	// digests are the signature digests of the programs, by directory.
+	digests map[string]string
+}
+
+// watch implements "watch [flags] <file.jack|dir>...", which compiles like
+// compile, then polls the sources and, whenever they change, rebuilds the
+// classes that changed, or every class of a program when the signatures of
+// its subroutines change, and prints the fresh diagnostics. With -run, the
+// programs rebuilt without errors are run in the VM emulator. It stops on an
+// interrupt and returns the exit status.
+func watch(args []string) int {
+	flags, b := newBuildFlags("watch")
+	interval := flags.Duration("interval", 500*time.Millisecond, "poll the sources every `duration`")
+	runPrograms := flags.Bool("run", false, "run each program rebuilt without errors in the VM emulator")
+	steps := flags.Int("steps", 10000000, "stop the programs run after `n` VM instructions, 0 for no limit")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	stage, emit, err := b.check(flags, "watch")
+	if err == nil && *interval <= 0 {
+		err = fmt.Errorf("-interval must be positive")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	w := &watcher{stamps: map[string]stamp{}, dirs: map[string]string{}, digests: map[string]string{}}
+	for {
+		programs, err := collectPrograms(flags.Args(), b.filter)
+		if err != nil {
+			// The sources may be in the middle of being moved.
+			fmt.Fprintln(os.Stderr, err)
+		} else if jobs := w.poll(b, programs); len(jobs) > 0 {
+			if !b.quiet {
+				classes := "classes"
+				if len(jobs) == 1 {
+					classes = "class"
+				}
+				fmt.Printf("-- %v: building %v %v\n", time.Now().Format("15:04:05"), len(jobs), classes)
+			}
+			b.runJobs(jobs, stage, emit)
+			if *runPrograms {
+				b.runRebuilt(jobs, *steps)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			return exitOK
+		}
+	}
+}
+
+// poll updates the state of the sources and returns the jobs that rebuild
+// the classes affected by the changes since the last poll.
+func (w *watcher) poll(b *buildFlags, programs []*program) []*job {
+	stamps := map[string]stamp{}
+	var jobs []*job
+	for _, p := range programs {
+		var dir []string
+		for _, file := range p.classes {
+			fInfo, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			stamps[file] = stamp{fInfo.ModTime().UnixNano(), fInfo.Size()}
+			dir = append(dir, fmt.Sprint(file, stamps[file]))
+		}
+		joined := strings.Join(dir, "\n")
+		if w.dirs[p.dir] == joined {
+			continue
+		}
+		w.dirs[p.dir] = joined
+
+		c, err := b.newCompiler(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		digest := c.SignatureDigest()
+		for _, file := range p.files {
+			old, ok := w.stamps[file]
+			if !ok || old != stamps[file] || w.digests[p.dir] != digest {
+				jobs = append(jobs, &job{program: p, compiler: c, file: file})
+			}
+		}
+		w.digests[p.dir] = digest
+	}
+	w.stamps = stamps
+	return jobs
+}
+
+// runRebuilt runs the programs of jobs whose classes were all built without
+// errors, printing what they print.
+func (b *buildFlags) runRebuilt(jobs []*job, steps int) {
+	var programs []*program
+	failed := map[*program]bool{}
+	for _, j := range jobs {
+		if j.err != nil {
+			failed[j.program] = true
+		}
+		if len(programs) == 0 || programs[len(programs)-1] != j.program {
+			programs = append(programs, j.program)
+		}
+	}
+	for _, p := range programs {
+		if failed[p] {
+			continue
+		}
+		// The diagnostics were printed by the build.
+		m, err := loadProgram(p.classes, b.options(), io.Discard)
+		if err != nil {
+			if _, ok := err.(diagnostic.List); !ok {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			continue
+		}
+		jackOS := jackos.New()
+		jackOS.Install(m)
+		m.MaxSteps = steps
+		if !b.quiet {
+			fmt.Printf("-- running %v\n", p.dir)
+		}
+		err = m.Run()
+		output := jackOS.Output.String()
+		fmt.Print(output)
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			fmt.Println()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package xmlwriter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/ast"
+	"github.com/jinpei0908/rewriting-JackCompiler/symboltable"
+)
+
+// Writer writes the XML parse tree of a class in the format of project 10 of
+// nand2tetris, which the TextComparer of the course accepts. With
+// SetIdentifierInfo, identifiers are also followed by an <identifierInfo>
+// element describing what the symbol table knows about them.
+type Writer struct {
+	w     io.Writer
+	st    *symboltable.SymbolTable
+	info  bool
+	depth int
+	err   error
+}
+
+var builtinTypes = []string{"int", "char", "boolean", "void"}
+
+// escaper escapes the characters that cannot appear as such in XML text.
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// Escape returns s with the characters that cannot appear as such in XML
+// text replaced by their entities.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:  w,
+		st: symboltable.NewSymbolTable()}
+}
+
+// SetIdentifierInfo enables the <identifierInfo> elements, which extend the
+// standard format.
+func (xw *Writer) SetIdentifierInfo(info bool) {
+	xw.info = info
+}
+
+// WriteClass writes class and returns the first write error, if any.
+func (xw *Writer) WriteClass(class *ast.Class) error {
+	xw.writeTag("<class>")
+	xw.writeKeyword("class")
+	xw.writeIdentifier(class.Name.Name)
+	xw.writeInfo("defined class")
+	xw.writeSymbol("{")
+	for _, dec := range class.ClassVarDecs {
+		xw.writeClassVarDec(dec)
+	}
+	for _, sub := range class.Subroutines {
+		xw.writeSubroutine(sub)
+	}
+	xw.writeSymbol("}")
+	xw.writeTag("</class>")
+	return xw.err
+}
+
+func (xw *Writer) writeClassVarDec(dec *ast.ClassVarDec) {
+	xw.writeTag("<classVarDec>")
+	xw.writeKeyword(dec.Kind)
+	xw.writeType(dec.Type)
+	xw.writeDefinedNames(dec.Names, dec.Type, dec.Kind)
+	xw.writeTag("</classVarDec>")
+}
+
+func (xw *Writer) writeSubroutine(sub *ast.Subroutine) {
+	xw.writeTag("<subroutineDec>")
+	xw.writeKeyword(sub.Kind)
+	xw.st.StartSubroutine(sub.Kind)
+	xw.writeType(sub.ReturnType)
+	xw.writeIdentifier(sub.Name.Name)
+	xw.writeInfo("defined subroutine")
+
+	xw.writeSymbol("(")
+	xw.writeTag("<parameterList>")
+	for i, param := range sub.Params {
+		if i > 0 {
+			xw.writeSymbol(",")
+		}
+		xw.writeType(param.Type)
+		xw.st.Define(param.Name.Name, param.Type, "arg")
+		xw.writeIdentifier(param.Name.Name)
+		xw.writeVarInfo("defined", param.Name.Name)
+	}
+	xw.writeTag("</parameterList>")
+	xw.writeSymbol(")")
+
+	xw.writeTag("<subroutineBody>")
+	xw.writeSymbol("{")
+	for _, dec := range sub.VarDecs {
+		xw.writeTag("<varDec>")
+		xw.writeKeyword("var")
+		xw.writeType(dec.Type)
+		xw.writeDefinedNames(dec.Names, dec.Type, "var")
+		xw.writeTag("</varDec>")
+	}
+	xw.writeStatements(sub.Statements)
+	xw.writeSymbol("}")
+	xw.writeTag("</subroutineBody>")
+	xw.writeTag("</subroutineDec>")
+}
+
+// writeDefinedNames writes "varName (',' varName)* ';'", defining each name.
+func (xw *Writer) writeDefinedNames(names []*ast.Ident, type_, kind string) {
+	for i, name := range names {
+		if i > 0 {
+			xw.writeSymbol(",")
+		}
+		xw.st.Define(name.Name, type_, kind)
+		xw.writeIdentifier(name.Name)
+		xw.writeVarInfo("defined", name.Name)
+	}
+	xw.writeSymbol(";")
+}
+
+func (xw *Writer) writeStatements(statements []ast.Statement) {
+	xw.writeTag("<statements>")
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.LetStatement:
+			xw.writeTag("<letStatement>")
+			xw.writeKeyword("let")
+			xw.writeIdentifier(s.Name.Name)
+			xw.writeVarInfo("used", s.Name.Name)
+			if s.Index != nil {
+				xw.writeSymbol("[")
+				xw.writeExpression(s.Index)
+				xw.writeSymbol("]")
+			}
+			xw.writeSymbol("=")
+			xw.writeExpression(s.Value)
+			xw.writeSymbol(";")
+			xw.writeTag("</letStatement>")
+
+		case *ast.IfStatement:
+			xw.writeTag("<ifStatement>")
+			xw.writeKeyword("if")
+			xw.writeSymbol("(")
+			xw.writeExpression(s.Cond)
+			xw.writeSymbol(")")
+			xw.writeBlock(s.Then)
+			if s.Else != nil {
+				xw.writeKeyword("else")
+				xw.writeBlock(s.Else)
+			}
+			xw.writeTag("</ifStatement>")
+
+		case *ast.WhileStatement:
+			xw.writeTag("<whileStatement>")
+			xw.writeKeyword("while")
+			xw.writeSymbol("(")
+			xw.writeExpression(s.Cond)
+			xw.writeSymbol(")")
+			xw.writeBlock(s.Body)
+			xw.writeTag("</whileStatement>")
+
+		case *ast.DoStatement:
+			xw.writeTag("<doStatement>")
+			xw.writeKeyword("do")
+			xw.writeSubroutineCall(s.Call)
+			xw.writeSymbol(";")
+			xw.writeTag("</doStatement>")
+
+		case *ast.ReturnStatement:
+			xw.writeTag("<returnStatement>")
+			xw.writeKeyword("return")
+			if s.Value != nil {
+				xw.writeExpression(s.Value)
+			}
+			xw.writeSymbol(";")
+			xw.writeTag("</returnStatement>")
+		}
+	}
+	xw.writeTag("</statements>")
+}
+
+func (xw *Writer) writeBlock(statements []ast.Statement) {
+	xw.writeSymbol("{")
+	xw.writeStatements(statements)
+	xw.writeSymbol("}")
+}
+
+func (xw *Writer) writeExpression(expr ast.Expression) {
+	xw.writeTag("<expression>")
+	xw.writeOperands(expr)
+	xw.writeTag("</expression>")
+}
+
+// writeOperands writes a left-nested chain of binary expressions as the flat
+// "term (op term)*" sequence it was parsed from.
+func (xw *Writer) writeOperands(expr ast.Expression) {
+	binary, ok := expr.(*ast.BinaryExpr)
+	if !ok {
+		xw.writeTerm(expr)
+		return
+	}
+	xw.writeOperands(binary.Left)
+	xw.writeSymbol(binary.Op)
+	xw.writeTerm(binary.Right)
+}
+
+func (xw *Writer) writeTerm(expr ast.Expression) {
+	xw.writeTag("<term>")
+	switch e := expr.(type) {
+	case *ast.IntegerConstant:
+		xw.writeTokenWithTag(fmt.Sprint(e.Value), "integerConstant")
+
+	case *ast.StringConstant:
+		xw.writeTokenWithTag(e.Value, "stringConstant")
+
+	case *ast.KeywordConstant:
+		xw.writeKeyword(e.Keyword)
+
+	case *ast.ParenExpr:
+		xw.writeSymbol("(")
+		xw.writeExpression(e.X)
+		xw.writeSymbol(")")
+
+	case *ast.UnaryExpr:
+		xw.writeSymbol(e.Op)
+		xw.writeTerm(e.Operand)
+
+	case *ast.VarExpr:
+		xw.writeIdentifier(e.Name)
+		xw.writeVarInfo("used", e.Name)
+
+	case *ast.IndexExpr:
+		xw.writeIdentifier(e.Name)
+		xw.writeVarInfo("used", e.Name)
+		xw.writeSymbol("[")
+		xw.writeExpression(e.Index)
+		xw.writeSymbol("]")
+
+	case *ast.CallExpr:
+		xw.writeSubroutineCall(e)
+
+	case *ast.BinaryExpr:
+		// Only reachable for trees not built by the parser.
+		xw.writeSymbol("(")
+		xw.writeExpression(e)
+		xw.writeSymbol(")")
+	}
+	xw.writeTag("</term>")
+}
+
+func (xw *Writer) writeSubroutineCall(call *ast.CallExpr) {
+	if call.Receiver == "" {
+		xw.writeIdentifier(call.Name)
+		xw.writeInfo("used subroutine")
+	} else {
+		xw.writeIdentifier(call.Receiver)
+		if _, err := xw.st.KindOf(call.Receiver); err != nil {
+			xw.writeInfo("used class")
+		} else {
+			xw.writeVarInfo("used", call.Receiver)
+		}
+		xw.writeSymbol(".")
+		xw.writeIdentifier(call.Name)
+		xw.writeInfo("used subroutine")
+	}
+
+	xw.writeSymbol("(")
+	xw.writeTag("<expressionList>")
+	for i, arg := range call.Args {
+		if i > 0 {
+			xw.writeSymbol(",")
+		}
+		xw.writeExpression(arg)
+	}
+	xw.writeTag("</expressionList>")
+	xw.writeSymbol(")")
+}
+
+func (xw *Writer) writeType(type_ string) {
+	for _, builtin := range builtinTypes {
+		if type_ == builtin {
+			xw.writeKeyword(type_)
+			return
+		}
+	}
+	xw.writeIdentifier(type_)
+}
+
+func (xw *Writer) writeKeyword(s string) {
+	xw.writeTokenWithTag(s, "keyword")
+}
+
+func (xw *Writer) writeIdentifier(s string) {
+	xw.writeTokenWithTag(s, "identifier")
+}
+
+func (xw *Writer) writeSymbol(s string) {
+	xw.writeTokenWithTag(s, "symbol")
+}
+
+func (xw *Writer) writeInfo(info string) {
+	if xw.info {
+		xw.writeTokenWithTag(info, "identifierInfo")
+	}
+}
+
+// writeVarInfo writes what the symbol table knows about a variable.
+// prefix is "defined" or "used".
+func (xw *Writer) writeVarInfo(prefix, name string) {
+	if !xw.info {
+		return
+	}
+	kind, _ := xw.st.KindOf(name)
+	index, _ := xw.st.IndexOf(name)
+	type_, _ := xw.st.TypeOf(name)
+	xw.writeInfo(fmt.Sprintf("%v %v %v %v", prefix, kind, index, type_))
+}
+
+// writeTag writes an opening or closing tag of a nonterminal, indenting its
+// contents by two spaces as the course files do.
+func (xw *Writer) writeTag(s string) {
+	if strings.HasPrefix(s, "</") {
+		xw.depth--
+		xw.write(strings.Repeat("  ", xw.depth) + s + "\n")
+		return
+	}
+	xw.write(strings.Repeat("  ", xw.depth) + s + "\n")
+	xw.depth++
+}
+
+func (xw *Writer) writeTokenWithTag(s, tagName string) {
+	xw.write(fmt.Sprintf("%s<%s> %s </%s>\n", strings.Repeat("  ", xw.depth), tagName, escaper.Replace(s), tagName))
+}
+
+func (xw *Writer) write(s string) {
+	if xw.err != nil {
+		return
+	}
+	_, xw.err = io.WriteString(xw.w, s)
+}
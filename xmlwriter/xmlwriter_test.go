@@ -0,0 +1,108 @@
+package xmlwriter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jinpei0908/rewriting-JackCompiler/internal/jacktest"
+)
+
+func writeString(t *testing.T, src string, info bool) string {
+	var out strings.Builder
+	xw := NewWriter(&out)
+	xw.SetIdentifierInfo(info)
+	if err := xw.WriteClass(jacktest.Parse(t, src)); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestWriteClass(t *testing.T) {
+	actual := writeString(t, `class Main {
+  static int n;
+  function void main() {
+    do Output.printString("a<b & c>");
+    return n < 10;
+  }
+}
+`, false)
+	expect := `<class>
+  <keyword> class </keyword>
+  <identifier> Main </identifier>
+  <symbol> { </symbol>
+  <classVarDec>
+    <keyword> static </keyword>
+    <keyword> int </keyword>
+    <identifier> n </identifier>
+    <symbol> ; </symbol>
+  </classVarDec>
+  <subroutineDec>
+    <keyword> function </keyword>
+    <keyword> void </keyword>
+    <identifier> main </identifier>
+    <symbol> ( </symbol>
+    <parameterList>
+    </parameterList>
+    <symbol> ) </symbol>
+    <subroutineBody>
+      <symbol> { </symbol>
+      <statements>
+        <doStatement>
+          <keyword> do </keyword>
+          <identifier> Output </identifier>
+          <symbol> . </symbol>
+          <identifier> printString </identifier>
+          <symbol> ( </symbol>
+          <expressionList>
+            <expression>
+              <term>
+                <stringConstant> a&lt;b &amp; c&gt; </stringConstant>
+              </term>
+            </expression>
+          </expressionList>
+          <symbol> ) </symbol>
+          <symbol> ; </symbol>
+        </doStatement>
+        <returnStatement>
+          <keyword> return </keyword>
+          <expression>
+            <term>
+              <identifier> n </identifier>
+            </term>
+            <symbol> &lt; </symbol>
+            <term>
+              <integerConstant> 10 </integerConstant>
+            </term>
+          </expression>
+          <symbol> ; </symbol>
+        </returnStatement>
+      </statements>
+      <symbol> } </symbol>
+    </subroutineBody>
+  </subroutineDec>
+  <symbol> } </symbol>
+</class>
+`
+	if actual != expect {
+		t.Errorf("\nactual:\n%v\nexpect:\n%v\n", actual, expect)
+	}
+}
+
+func TestWriteIdentifierInfo(t *testing.T) {
+	actual := writeString(t, `class Main {
+  function int f(int a) {
+    return a;
+  }
+}
+`, true)
+	for _, expect := range []string{
+		"  <identifier> Main </identifier>\n  <identifierInfo> defined class </identifierInfo>\n",
+		"    <identifier> f </identifier>\n    <identifierInfo> defined subroutine </identifierInfo>\n",
+		"<identifierInfo> defined Argument 0 int </identifierInfo>\n",
+		"<identifierInfo> used Argument 0 int </identifierInfo>\n",
+	} {
+		if !strings.Contains(actual, expect) {
+			t.Errorf("\nactual:\n%v\nexpect to contain:\n%v\n", actual, expect)
+		}
+	}
+}